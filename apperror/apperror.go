@@ -2,12 +2,14 @@ package apperror
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"runtime"
 	"sync/atomic"
 
-	"github.com/pkg/errors"
+	pkgerrors "github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // based on github/pkg/errors and grpc
@@ -21,11 +23,23 @@ const (
 	ErrForbidden    = "ERR_FORBIDDEN"
 	ErrNotFound     = "ERR_NOTFOUND"
 	ErrInternal     = "ERR_INTERNAL"
+
+	ErrAlreadyExists      = "ERR_ALREADY_EXISTS"
+	ErrConflict           = "ERR_CONFLICT"
+	ErrFailedPrecondition = "ERR_FAILED_PRECONDITION"
+	ErrResourceExhausted  = "ERR_RESOURCE_EXHAUSTED"
+	ErrUnavailable        = "ERR_UNAVAILABLE"
+	ErrDeadlineExceeded   = "ERR_DEADLINE_EXCEEDED"
+	ErrUnimplemented      = "ERR_UNIMPLEMENTED"
+	ErrCanceled           = "ERR_CANCELED"
 )
 
 type Err struct {
-	Code       string   `json:"code"`
-	Message    string   `json:"message"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	ErrorID string            `json:"error_id,omitempty"`
+	Details []json.RawMessage `json:"details,omitempty"`
+	// Deprecated: stack traces are not sent to clients anymore, they are logged by the internal error logger.
 	StackTrace []string `json:"stack_trace,omitempty"`
 	InnerError error    `json:"-"`
 }
@@ -35,7 +49,14 @@ type appError struct {
 	Code     string
 	Message  string
 	InnerErr error
-	Stack    errors.StackTrace
+	Stack    pkgerrors.StackTrace
+	Details  []proto.Message
+	ErrorID  string
+	Params   map[string]interface{}
+	// localized is set if Message was rendered from the catalog, it is shown to clients even if e is internal.
+	localized bool
+	// rawMessage is the message of e before localization, which the internal error logger reports.
+	rawMessage string
 }
 
 func (e *appError) Error() string {
@@ -46,25 +67,28 @@ func (e *appError) StackTrace() []string {
 	if !EnableStackTrace.Load() {
 		return nil
 	}
-	if len(e.Stack) == 0 {
-		return nil
-	}
-	st := make([]string, len(e.Stack))
-	for i, s := range e.Stack {
-		st[i] = fmt.Sprintf("%+v", s)
-	}
-	return st
+	return e.frames()
 }
 
 func (e *appError) StatusCode() codes.Code {
 	return e.Status
 }
 
+// GRPCStatus returns the status sent to clients for e, so that status.Code and status.FromError
+// work on apperrors, including the ones decoded by FromGrpcError.
+func (e *appError) GRPCStatus() *status.Status {
+	return e.grpcStatus()
+}
+
+// ToJSON returns the JSON sent to clients. Messages of internal errors are redacted, see SetRedactedMessage.
 func (e *appError) ToJSON() string {
 	err := &Err{
-		Code:       e.Code,
-		Message:    e.Message,
-		StackTrace: e.StackTrace(),
+		Code:    e.Code,
+		Message: e.Message,
+		ErrorID: e.ErrorID,
+	}
+	if isRedacted(e.Status) && !e.localized {
+		err.Message = getRedactedMessage()
 	}
 	bytes, _ := json.Marshal(err)
 	return string(bytes)
@@ -74,6 +98,86 @@ func (e *appError) Inner() error {
 	return e.InnerErr
 }
 
+func (e *appError) Unwrap() error {
+	return e.InnerErr
+}
+
+// Is reports whether target is an apperror with the same code as e.
+func (e *appError) Is(target error) bool {
+	t, ok := target.(*appError)
+	return ok && t.Code == e.Code
+}
+
+func (e *appError) ErrorCode() string {
+	return e.Code
+}
+
+func (e *appError) ErrorMessage() string {
+	return e.Message
+}
+
+// Error is implemented by all apperrors.
+type Error interface {
+	error
+	StatusCode() codes.Code
+	ErrorCode() string
+	ErrorMessage() string
+	Inner() error
+}
+
+// As returns the first apperror in the chain of err.
+func As(err error) (Error, bool) {
+	appErr := &appError{}
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// CodeOf returns the apperror code of err. gRPC status errors get the default code of their status,
+// other errors ErrInternal. Returns an empty string if err is nil.
+func CodeOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	if appErr, ok := As(err); ok {
+		return appErr.ErrorCode()
+	}
+	if st, ok := status.FromError(err); ok {
+		return codeForStatus(st.Code())
+	}
+	return ErrInternal
+}
+
+// StatusOf returns the gRPC status code of err, codes.Unknown for errors which are neither apperrors
+// nor gRPC status errors and codes.OK if err is nil.
+func StatusOf(err error) codes.Code {
+	if appErr, ok := As(err); ok {
+		return appErr.StatusCode()
+	}
+	return status.Code(err)
+}
+
+// Wrap returns a copy of the apperror err with msg prepended to its message, keeping its code,
+// status, details and stack. err stays in the chain. Other errors are wrapped as internal errors.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	appErr := &appError{}
+	if !errors.As(err, &appErr) {
+		return makeError(codes.Internal, ErrInternal, msg+": "+err.Error(), err)
+	}
+	cp := *appErr
+	cp.Message = msg + ": " + appErr.Message
+	cp.InnerErr = err
+	return &cp
+}
+
+func Wrapf(err error, format string, args ...interface{}) error {
+	return Wrap(err, fmt.Sprintf(format, args...))
+}
+
 func NotFound(msg string) error {
 	return makeError(codes.NotFound, ErrNotFound, msg, nil)
 }
@@ -122,6 +226,106 @@ func BadRequestWithCodeE(code string, msg string, err error) error {
 	return makeError(codes.InvalidArgument, code, msg, err)
 }
 
+func AlreadyExists(msg string) error {
+	return makeError(codes.AlreadyExists, ErrAlreadyExists, msg, nil)
+}
+
+func AlreadyExistsWithCode(code string, msg string) error {
+	return makeError(codes.AlreadyExists, code, msg, nil)
+}
+
+func AlreadyExistsWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.AlreadyExists, code, msg, err)
+}
+
+// Conflict reports a concurrency conflict, e.g. a failed optimistic lock. It maps to codes.Aborted.
+func Conflict(msg string) error {
+	return makeError(codes.Aborted, ErrConflict, msg, nil)
+}
+
+func ConflictWithCode(code string, msg string) error {
+	return makeError(codes.Aborted, code, msg, nil)
+}
+
+func ConflictWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.Aborted, code, msg, err)
+}
+
+func FailedPrecondition(msg string) error {
+	return makeError(codes.FailedPrecondition, ErrFailedPrecondition, msg, nil)
+}
+
+func FailedPreconditionWithCode(code string, msg string) error {
+	return makeError(codes.FailedPrecondition, code, msg, nil)
+}
+
+func FailedPreconditionWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.FailedPrecondition, code, msg, err)
+}
+
+// ResourceExhausted reports an exceeded quota or rate limit.
+func ResourceExhausted(msg string) error {
+	return makeError(codes.ResourceExhausted, ErrResourceExhausted, msg, nil)
+}
+
+func ResourceExhaustedWithCode(code string, msg string) error {
+	return makeError(codes.ResourceExhausted, code, msg, nil)
+}
+
+func ResourceExhaustedWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.ResourceExhausted, code, msg, err)
+}
+
+func Unavailable(msg string) error {
+	return makeError(codes.Unavailable, ErrUnavailable, msg, nil)
+}
+
+func UnavailableWithCode(code string, msg string) error {
+	return makeError(codes.Unavailable, code, msg, nil)
+}
+
+func UnavailableWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.Unavailable, code, msg, err)
+}
+
+func DeadlineExceeded(msg string) error {
+	return makeError(codes.DeadlineExceeded, ErrDeadlineExceeded, msg, nil)
+}
+
+func DeadlineExceededWithCode(code string, msg string) error {
+	return makeError(codes.DeadlineExceeded, code, msg, nil)
+}
+
+func DeadlineExceededWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.DeadlineExceeded, code, msg, err)
+}
+
+func Unimplemented(msg string) error {
+	return makeError(codes.Unimplemented, ErrUnimplemented, msg, nil)
+}
+
+func UnimplementedWithCode(code string, msg string) error {
+	return makeError(codes.Unimplemented, code, msg, nil)
+}
+
+func UnimplementedWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.Unimplemented, code, msg, err)
+}
+
+func Canceled(msg string) error {
+	return makeError(codes.Canceled, ErrCanceled, msg, nil)
+}
+
+func CanceledWithCode(code string, msg string) error {
+	return makeError(codes.Canceled, code, msg, nil)
+}
+
+func CanceledWithCodeE(code string, msg string, err error) error {
+	return makeError(codes.Canceled, code, msg, err)
+}
+
+// InternalError returns an internal error wrapping err. Its message is only logged, clients get
+// the redacted message and the ErrorID instead.
 func InternalError(err error) error {
 	msg := ""
 	if err == nil {
@@ -133,23 +337,24 @@ func InternalError(err error) error {
 }
 
 func makeError(statusCode codes.Code, appErrCode string, msg string, err error) *appError {
-	return &appError{
+	e := newError(statusCode, appErrCode, msg, err)
+	if captureStack(e.Status) {
+		e.Stack = stackTrace(4)
+	}
+	return e
+}
+
+// newError returns an apperror without stack, see makeError.
+func newError(statusCode codes.Code, appErrCode string, msg string, err error) *appError {
+	statusCode, appErrCode, err = checkCode(statusCode, appErrCode, err)
+	e := &appError{
 		Status:   statusCode,
 		Code:     appErrCode,
 		Message:  msg,
 		InnerErr: err,
-		Stack:    stackTrace(),
 	}
-}
-
-func stackTrace() errors.StackTrace {
-	const depth = 32
-	var pcs [depth]uintptr
-	n := runtime.Callers(4, pcs[:]) - 1
-	var st = pcs[0:n]
-	f := make([]errors.Frame, len(st))
-	for i := 0; i < len(f); i++ {
-		f[i] = errors.Frame(st[i])
+	if isRedacted(statusCode) {
+		e.ErrorID = newErrorID()
 	}
-	return f
+	return e
 }
@@ -0,0 +1,92 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// FromGrpcError reconstructs the apperror of a status error returned by a service using WrapGrpcError,
+// with its code, message, error ID and details. Other status errors become apperrors with the default
+// code of their status. Apperrors and errors which are not status errors are returned unchanged.
+func FromGrpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := As(err); ok {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	e := makeError(st.Code(), codeForStatus(st.Code()), st.Message(), err)
+	remote := Err{}
+	if json.Unmarshal([]byte(st.Message()), &remote) == nil && remote.Code != "" {
+		e.Code = remote.Code
+		e.Message = remote.Message
+		if remote.ErrorID != "" {
+			e.ErrorID = remote.ErrorID
+		}
+	}
+	for _, d := range st.Details() {
+		if m, ok := d.(proto.Message); ok {
+			e.Details = append(e.Details, m)
+		}
+	}
+	return e
+}
+
+// DecodeGrpcError is a unary client interceptor converting the errors of calls with FromGrpcError.
+func DecodeGrpcError(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return FromGrpcError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// DecodeGrpcStreamError is a stream client interceptor converting the errors of streams with FromGrpcError.
+func DecodeGrpcStreamError(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, FromGrpcError(err)
+	}
+	return &decodingClientStream{ClientStream: cs}, nil
+}
+
+type decodingClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *decodingClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	return md, FromGrpcError(err)
+}
+
+func (s *decodingClientStream) CloseSend() error {
+	return FromGrpcError(s.ClientStream.CloseSend())
+}
+
+func (s *decodingClientStream) SendMsg(m interface{}) error {
+	return FromGrpcError(s.ClientStream.SendMsg(m))
+}
+
+func (s *decodingClientStream) RecvMsg(m interface{}) error {
+	return FromGrpcError(s.ClientStream.RecvMsg(m))
+}
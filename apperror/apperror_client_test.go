@@ -0,0 +1,136 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	err error
+}
+
+func (s *healthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return nil, s.err
+}
+
+func (s *healthServer) Watch(_ *grpc_health_v1.HealthCheckRequest, ss grpc_health_v1.Health_WatchServer) error {
+	return s.err
+}
+
+func newHealthClient(t *testing.T, err error) grpc_health_v1.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(WrapGrpcError),
+		grpc.StreamInterceptor(WrapGrpcStreamError),
+	)
+	grpc_health_v1.RegisterHealthServer(srv, &healthServer{err: err})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, dialErr := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(DecodeGrpcError),
+		grpc.WithStreamInterceptor(DecodeGrpcStreamError),
+	)
+	if dialErr != nil {
+		t.Fatalf("grpc.Dial() error = %v", dialErr)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestDecodeGrpcError(t *testing.T) {
+	defer SetInternalErrorLogger(nil)
+	SetInternalErrorLogger(func(context.Context, *InternalErrorReport) {})
+
+	serverErr := WithResourceInfo(NotFoundWithCode("ERR_USER_NOTFOUND", "user not found"), "user", "users/1", "", "")
+	internalErr := InternalError(errors.New("connection refused"))
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  codes.Code
+		wantCode    string
+		wantMessage string
+		wantID      string
+		wantDetails int
+	}{
+		{"apperror", serverErr, codes.NotFound, "ERR_USER_NOTFOUND", "user not found", "", 1},
+		{"internal", internalErr, codes.Internal, ErrInternal, DefaultRedactedMessage, ErrorID(internalErr), 0},
+		{"status", status.Error(codes.Unavailable, "draining"), codes.Unavailable, ErrUnavailable, "draining", "", 0},
+	}
+	for _, tt := range tests {
+		check := func(t *testing.T, err error) {
+			t.Helper()
+			appErr, ok := As(err)
+			if !ok {
+				t.Fatalf("error = %v, want apperror", err)
+			}
+			if appErr.StatusCode() != tt.wantStatus || appErr.ErrorCode() != tt.wantCode || appErr.ErrorMessage() != tt.wantMessage {
+				t.Errorf("error = %v (%v), want %v %v %v", err, appErr.StatusCode(), tt.wantStatus, tt.wantCode, tt.wantMessage)
+			}
+			if ErrorID(err) != tt.wantID {
+				t.Errorf("ErrorID() = %v, want %v", ErrorID(err), tt.wantID)
+			}
+			if details := Details(err); len(details) != tt.wantDetails {
+				t.Errorf("Details() = %v, want %d details", details, tt.wantDetails)
+			}
+		}
+		t.Run(tt.name+" unary", func(t *testing.T) {
+			client := newHealthClient(t, tt.err)
+			_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			check(t, err)
+		})
+		t.Run(tt.name+" stream", func(t *testing.T) {
+			client := newHealthClient(t, tt.err)
+			stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			if err != nil {
+				t.Fatalf("Watch() error = %v", err)
+			}
+			_, err = stream.Recv()
+			check(t, err)
+		})
+	}
+}
+
+func TestFromGrpcError(t *testing.T) {
+	if FromGrpcError(nil) != nil {
+		t.Error("FromGrpcError(nil) != nil")
+	}
+	if err := FromGrpcError(io.EOF); err != io.EOF {
+		t.Errorf("FromGrpcError(io.EOF) = %v", err)
+	}
+	appErr := BadRequest("invalid")
+	if err := FromGrpcError(appErr); err != appErr {
+		t.Errorf("FromGrpcError() = %v, want %v", err, appErr)
+	}
+
+	st, _ := status.New(codes.InvalidArgument, `{"code":"ERR_INVALID_EMAIL","message":"invalid email"}`).
+		WithDetails(&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{FieldViolation("email", "invalid")}})
+	err := FromGrpcError(st.Err())
+	if !errors.Is(err, BadRequestWithCode("ERR_INVALID_EMAIL", "")) {
+		t.Errorf("FromGrpcError() = %v, want ERR_INVALID_EMAIL", err)
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("status.Code() = %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+	if status.Code(errors.Unwrap(err)) != codes.InvalidArgument {
+		t.Errorf("FromGrpcError() does not wrap the status error")
+	}
+	if details := Details(err); len(details) != 1 {
+		t.Errorf("Details() = %v, want 1 detail", details)
+	}
+}
@@ -0,0 +1,116 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WithDetails returns a copy of the apperror err with google.rpc error details attached,
+// e.g. *errdetails.BadRequest. Errors which are not apperrors are wrapped with InternalError first.
+func WithDetails(err error, details ...proto.Message) error {
+	if err == nil {
+		return nil
+	}
+	appErr := &appError{}
+	if !errors.As(err, &appErr) {
+		appErr = InternalError(err).(*appError)
+	}
+	cp := *appErr
+	cp.Details = append(cp.Details[:len(cp.Details):len(cp.Details)], details...)
+	return &cp
+}
+
+// Details returns the error details attached to err.
+func Details(err error) []proto.Message {
+	appErr := &appError{}
+	if errors.As(err, &appErr) {
+		return appErr.Details
+	}
+	return nil
+}
+
+func FieldViolation(field string, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+func BadRequestWithViolations(msg string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	err := makeError(codes.InvalidArgument, ErrBadRequest, msg, nil)
+	err.Details = []proto.Message{&errdetails.BadRequest{FieldViolations: violations}}
+	return err
+}
+
+func WithErrorInfo(err error, reason string, domain string, metadata map[string]string) error {
+	return WithDetails(err, &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   domain,
+		Metadata: metadata,
+	})
+}
+
+func WithRetryInfo(err error, retryDelay time.Duration) error {
+	return WithDetails(err, &errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryDelay),
+	})
+}
+
+func WithResourceInfo(err error, resourceType string, resourceName string, owner string, description string) error {
+	return WithDetails(err, &errdetails.ResourceInfo{
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Owner:        owner,
+		Description:  description,
+	})
+}
+
+func WithLocalizedMessage(err error, locale string, message string) error {
+	return WithDetails(err, &errdetails.LocalizedMessage{
+		Locale:  locale,
+		Message: message,
+	})
+}
+
+// grpcStatus returns the status of e, with its details as status details.
+func (e *appError) grpcStatus() *status.Status {
+	st := &spb.Status{
+		Code:    int32(e.Status),
+		Message: e.ToJSON(),
+	}
+	for _, d := range e.Details {
+		a, err := anypb.New(d)
+		if err != nil {
+			continue
+		}
+		st.Details = append(st.Details, a)
+	}
+	return status.FromProto(st)
+}
+
+// renderDetails renders the details of st as JSON objects with an @type member.
+// Details of unknown types are left out.
+func renderDetails(st *status.Status) []json.RawMessage {
+	res := []json.RawMessage{}
+	for _, d := range st.Proto().GetDetails() {
+		b, err := protojson.Marshal(d)
+		if err != nil {
+			continue
+		}
+		res = append(res, b)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
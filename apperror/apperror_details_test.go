@@ -0,0 +1,119 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithDetails(t *testing.T) {
+	base := NotFound("user not found")
+	err := WithResourceInfo(base, "user", "users/1", "", "")
+	if got := Details(base); len(got) != 0 {
+		t.Errorf("Details() of original = %v, want none", got)
+	}
+	details := Details(err)
+	if len(details) != 1 {
+		t.Fatalf("Details() = %v, want 1 detail", details)
+	}
+	if info, ok := details[0].(*errdetails.ResourceInfo); !ok || info.ResourceName != "users/1" {
+		t.Errorf("Details() = %v, want resource info", details)
+	}
+	if err.Error() != base.Error() {
+		t.Errorf("WithDetails() error = %v, want %v", err, base)
+	}
+
+	err = WithRetryInfo(errors.New("overloaded"), time.Second)
+	appErr := &appError{}
+	if !errors.As(err, &appErr) || appErr.Code != ErrInternal {
+		t.Errorf("WithDetails() = %v, want internal error", err)
+	}
+	if WithDetails(nil, &errdetails.RetryInfo{}) != nil {
+		t.Error("WithDetails(nil) != nil")
+	}
+}
+
+func TestWrapGrpcError_details(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, WithErrorInfo(
+			BadRequestWithViolations("invalid user", FieldViolation("email", "must not be empty")),
+			"INVALID_USER", "users.example.com", map[string]string{"field": "email"},
+		)
+	}
+	_, err := WrapGrpcError(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("WrapGrpcError() code = %v, want %v", st.Code(), codes.InvalidArgument)
+	}
+	if st.Message() != `{"code":"ERR_BAD_REQUEST","message":"invalid user"}` {
+		t.Errorf("WrapGrpcError() message = %v", st.Message())
+	}
+	details := st.Details()
+	if len(details) != 2 {
+		t.Fatalf("WrapGrpcError() details = %v, want 2", details)
+	}
+	want := &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{FieldViolation("email", "must not be empty")}}
+	if br, ok := details[0].(*errdetails.BadRequest); !ok || !proto.Equal(br, want) {
+		t.Errorf("WrapGrpcError() details[0] = %v, want %v", details[0], want)
+	}
+	if info, ok := details[1].(*errdetails.ErrorInfo); !ok || info.Reason != "INVALID_USER" {
+		t.Errorf("WrapGrpcError() details[1] = %v, want error info", details[1])
+	}
+}
+
+func TestFormatRestError_details(t *testing.T) {
+	err := WithLocalizedMessage(BadRequestWithViolations("invalid", FieldViolation("name", "too long")), "vi-VN", "Không hợp lệ")
+	appErr := &appError{}
+	errors.As(err, &appErr)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		wantBody string
+	}{
+		{
+			name: "normalized",
+			ctx: runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+				HeaderMD: metadata.Pairs(errorNormalizedFlag, "OK"),
+			}),
+			err: appErr.grpcStatus().Err(),
+			wantBody: `{"code":"ERR_BAD_REQUEST","message":"invalid","details":[` +
+				`{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"name","description":"too long"}]},` +
+				`{"@type":"type.googleapis.com/google.rpc.LocalizedMessage","locale":"vi-VN","message":"Không hợp lệ"}]}`,
+		},
+		{
+			name: "plain status",
+			ctx:  context.Background(),
+			err: func() error {
+				st, _ := status.New(codes.InvalidArgument, "invalid").WithDetails(&errdetails.RetryInfo{})
+				return st.Err()
+			}(),
+			wantBody: `{"code":"ERR_BAD_REQUEST","message":"invalid","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			FormatRestError(tt.ctx, nil, nil, w, nil, tt.err)
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("FormatRestError() body = %v, want %v", got, tt.wantBody)
+			}
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("FormatRestError() status = %v, want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
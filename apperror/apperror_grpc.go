@@ -15,12 +15,13 @@ import (
 
 var (
 	errorNormalizedFlag      = "x-rpc-err-normalized"
-	AllowedHTTPErrorStatuses = []int{400, 401, 403, 404}
+	AllowedHTTPErrorStatuses = []int{400, 401, 403, 404, 408, 409, 429, 501, 503, 504}
 )
 
 type errorStruct struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details,omitempty"`
 }
 
 func MessageForGrpcStatus(err *status.Status) []byte {
@@ -28,23 +29,44 @@ func MessageForGrpcStatus(err *status.Status) []byte {
 	msg := err.Message()
 
 	e := errorStruct{
+		Code:    codeForStatus(code),
 		Message: msg,
+		Details: renderDetails(err),
 	}
+	b, _ := json.Marshal(e)
+	return b
+}
+
+func codeForStatus(code codes.Code) string {
 	// nolint: exhaustive // We don't care other status.
 	switch code {
 	case codes.InvalidArgument:
-		e.Code = ErrBadRequest
+		return ErrBadRequest
 	case codes.Unauthenticated:
-		e.Code = ErrUnauthorized
+		return ErrUnauthorized
 	case codes.PermissionDenied:
-		e.Code = ErrForbidden
+		return ErrForbidden
 	case codes.NotFound:
-		e.Code = ErrNotFound
+		return ErrNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	case codes.Aborted:
+		return ErrConflict
+	case codes.FailedPrecondition:
+		return ErrFailedPrecondition
+	case codes.ResourceExhausted:
+		return ErrResourceExhausted
+	case codes.Unavailable:
+		return ErrUnavailable
+	case codes.DeadlineExceeded:
+		return ErrDeadlineExceeded
+	case codes.Unimplemented:
+		return ErrUnimplemented
+	case codes.Canceled:
+		return ErrCanceled
 	default:
-		e.Code = ErrInternal
+		return ErrInternal
 	}
-	b, _ := json.Marshal(e)
-	return b
 }
 
 func WrapGrpcError(
@@ -57,37 +79,101 @@ func WrapGrpcError(
 	if err == nil {
 		return resp, nil
 	}
-	msg := &appError{}
-	ok := errors.As(err, &msg)
+	method := ""
+	if info != nil {
+		method = info.FullMethod
+	}
+	err, ok := normalize(ctx, method, err)
 	if ok {
 		_ = grpc.SetHeader(ctx, metadata.Pairs(errorNormalizedFlag, "OK"))
-		return nil, status.Error(msg.StatusCode(), msg.ToJSON())
 	}
-
 	return nil, err
 }
 
+// WrapGrpcStreamError is the stream counterpart of WrapGrpcError.
+func WrapGrpcStreamError(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	err := handler(srv, ss)
+	if err == nil {
+		return nil
+	}
+	err, ok := normalize(ss.Context(), info.FullMethod, err)
+	if ok {
+		// Fails if the headers have been sent already, the error is normalized anyway.
+		_ = ss.SetHeader(metadata.Pairs(errorNormalizedFlag, "OK"))
+	}
+	return err
+}
+
+// normalize translates err and converts it into a status error, logging internal errors.
+// It reports false if err is not an apperror after translation.
+func normalize(ctx context.Context, method string, err error) (error, bool) {
+	err = Translate(err)
+	msg := &appError{}
+	if !errors.As(err, &msg) {
+		return err, false
+	}
+	if isRedacted(msg.Status) {
+		logInternalError(ctx, msg.report(ctx, method))
+	}
+	return msg.localize(LocaleFromContext(ctx)).grpcStatus().Err(), true
+}
+
+// FormatRestError is a runtime.ErrorHandlerFunc writing errors as {"code","message"} objects,
+// or as problem details depending on SetRestErrorFormat and the Accept header of r.
 func FormatRestError(ctx context.Context, sm *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
 	md, _ := runtime.ServerMetadataFromContext(ctx)
 
-	w.Header().Add("Content-Type", "application/json")
 	grpcErr := status.Convert(err)
 
-	statusCode := runtime.HTTPStatusFromCode(grpcErr.Code())
-	if !isHTTPCodeAllowed(statusCode) {
-		statusCode = http.StatusInternalServerError
-	}
-
-	w.WriteHeader(statusCode)
+	statusCode := httpStatusFromCode(grpcErr.Code())
 	var bytes []byte
 	if len(md.HeaderMD[errorNormalizedFlag]) > 0 {
 		bytes = []byte(grpcErr.Message())
+		if details := renderDetails(grpcErr); len(details) > 0 {
+			bytes = messageWithDetails(bytes, details)
+		}
+		if info, ok := LookupCode(codeOfMessage(bytes)); ok && info.HTTPStatus != 0 {
+			statusCode = info.HTTPStatus
+		}
 	} else {
+		if r != nil {
+			grpcErr = localizeStatus(grpcErr, NegotiateLocale(r.Header.Get("Accept-Language")))
+		}
 		bytes = MessageForGrpcStatus(grpcErr)
 	}
+
+	if errorFormat(r) == ErrorFormatProblem {
+		w.Header().Add("Content-Type", ProblemContentType)
+		bytes = problemFor(bytes, grpcErr, statusCode, r)
+	} else {
+		w.Header().Add("Content-Type", "application/json")
+	}
+	w.WriteHeader(statusCode)
 	_, _ = w.Write(bytes)
 }
 
+// httpStatusFromCode returns the HTTP status grpc-gateway maps code to,
+// or 500 if it is not in AllowedHTTPErrorStatuses.
+func httpStatusFromCode(code codes.Code) int {
+	statusCode := runtime.HTTPStatusFromCode(code)
+	if !isHTTPCodeAllowed(statusCode) {
+		return http.StatusInternalServerError
+	}
+	return statusCode
+}
+
+// codeOfMessage returns the code of a message created by appError.ToJSON.
+func codeOfMessage(msg []byte) string {
+	e := Err{}
+	_ = json.Unmarshal(msg, &e)
+	return e.Code
+}
+
 func isHTTPCodeAllowed(statusCode int) bool {
 	for _, val := range AllowedHTTPErrorStatuses {
 		if statusCode == val {
@@ -96,3 +182,14 @@ func isHTTPCodeAllowed(statusCode int) bool {
 	}
 	return false
 }
+
+// messageWithDetails adds details to a message created by appError.ToJSON.
+func messageWithDetails(msg []byte, details []json.RawMessage) []byte {
+	e := Err{}
+	if err := json.Unmarshal(msg, &e); err != nil {
+		return msg
+	}
+	e.Details = details
+	b, _ := json.Marshal(e)
+	return b
+}
@@ -41,6 +41,26 @@ func TestMessageForGrpcStatus(t *testing.T) {
 			err:  status.New(codes.InvalidArgument, "badrequest"),
 			want: `{"code":"ERR_BAD_REQUEST","message":"badrequest"}`,
 		},
+		{
+			name: "already exists",
+			err:  status.New(codes.AlreadyExists, "exists"),
+			want: `{"code":"ERR_ALREADY_EXISTS","message":"exists"}`,
+		},
+		{
+			name: "conflict",
+			err:  status.New(codes.Aborted, "conflict"),
+			want: `{"code":"ERR_CONFLICT","message":"conflict"}`,
+		},
+		{
+			name: "rate limited",
+			err:  status.New(codes.ResourceExhausted, "slow down"),
+			want: `{"code":"ERR_RESOURCE_EXHAUSTED","message":"slow down"}`,
+		},
+		{
+			name: "unknown",
+			err:  status.New(codes.DataLoss, "lost"),
+			want: `{"code":"ERR_INTERNAL","message":"lost"}`,
+		},
 		{
 			name: "internal",
 			err:  status.New(codes.Internal, "internal"),
@@ -125,8 +145,13 @@ func Test_isHTTPCodeAllowed(t *testing.T) {
 			want:       true,
 		},
 		{
-			name:       "not ok",
+			name:       "not implemented",
 			statusCode: 501,
+			want:       true,
+		},
+		{
+			name:       "not ok",
+			statusCode: 502,
 			want:       false,
 		},
 	}
@@ -163,6 +188,20 @@ func TestFormatRestError(t *testing.T) {
 			wantBody:   []byte(`Bypass`),
 			wantStatus: http.StatusNotFound,
 		},
+		{
+			name:       "rate limited",
+			ctx:        context.Background(),
+			err:        status.Error(codes.ResourceExhausted, "slow down"),
+			wantBody:   []byte(`{"code":"ERR_RESOURCE_EXHAUSTED","message":"slow down"}`),
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "unavailable",
+			ctx:        context.Background(),
+			err:        status.Error(codes.Unavailable, "down"),
+			wantBody:   []byte(`{"code":"ERR_UNAVAILABLE","message":"down"}`),
+			wantStatus: http.StatusServiceUnavailable,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,200 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const acceptLanguageKey = "accept-language"
+
+var (
+	// defaultLocale holds the locale set by SetDefaultLocale, "en" if unset.
+	defaultLocale atomic.Value
+
+	catalogMu sync.RWMutex
+	// catalog contains the message templates by locale and apperror code.
+	catalog = map[string]map[string]*template.Template{}
+)
+
+// SetDefaultLocale sets the locale used when a client accepts none of the locales of the catalog,
+// and for codes without a message in the negotiated locale. It is "en" by default.
+func SetDefaultLocale(locale string) {
+	defaultLocale.Store(normalizeLocale(locale))
+}
+
+func getDefaultLocale() string {
+	if locale, ok := defaultLocale.Load().(string); ok {
+		return locale
+	}
+	return "en"
+}
+
+// RegisterMessages adds the messages of locale to the catalog, keyed by apperror code. Messages are
+// text/template templates executed with the params of the error, e.g. "User {{.name}} not found".
+// Messages of internal errors are shown to clients instead of the redacted message.
+func RegisterMessages(locale string, messages map[string]string) error {
+	parsed := make(map[string]*template.Template, len(messages))
+	for code, msg := range messages {
+		t, err := template.New(code).Parse(msg)
+		if err != nil {
+			return fmt.Errorf("apperror: message %s of locale %s: %w", code, locale, err)
+		}
+		parsed[code] = t
+	}
+
+	locale = normalizeLocale(locale)
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if catalog[locale] == nil {
+		catalog[locale] = map[string]*template.Template{}
+	}
+	for code, t := range parsed {
+		catalog[locale][code] = t
+	}
+	return nil
+}
+
+// WithParams returns a copy of the apperror err with params for its catalog message.
+// Errors which are not apperrors are wrapped with InternalError first.
+func WithParams(err error, params map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	appErr := &appError{}
+	if !errors.As(err, &appErr) {
+		appErr = InternalError(err).(*appError)
+	}
+	cp := *appErr
+	cp.Params = make(map[string]interface{}, len(appErr.Params)+len(params))
+	for k, v := range appErr.Params {
+		cp.Params[k] = v
+	}
+	for k, v := range params {
+		cp.Params[k] = v
+	}
+	return &cp
+}
+
+// Localize returns a copy of the apperror err with its message rendered from the catalog for locale.
+// err is returned unchanged if it is not an apperror or the catalog has no message for its code.
+func Localize(err error, locale string) error {
+	appErr := &appError{}
+	if !errors.As(err, &appErr) {
+		return err
+	}
+	return appErr.localize(normalizeLocale(locale))
+}
+
+// NegotiateLocale returns the catalog locale best matching an Accept-Language value, or the default locale.
+// A locale matches a language range with the same language, e.g. "vi" matches "vi-VN".
+func NegotiateLocale(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	ranges := []weighted{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		w := weighted{tag: normalizeLocale(tag), q: 1}
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				w.q = v
+			}
+		}
+		if w.tag != "" && w.tag != "*" && w.q > 0 {
+			ranges = append(ranges, w)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	locales := make([]string, 0, len(catalog))
+	for l := range catalog {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	for _, r := range ranges {
+		if _, ok := catalog[r.tag]; ok {
+			return r.tag
+		}
+		lang, _, _ := strings.Cut(r.tag, "-")
+		for _, l := range locales {
+			if l == lang || strings.HasPrefix(l, lang+"-") {
+				return l
+			}
+		}
+	}
+	return getDefaultLocale()
+}
+
+// LocaleFromContext negotiates the locale of the accept-language gRPC metadata of ctx, which is
+// forwarded by grpc-gateway as grpcgateway-accept-language.
+func LocaleFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{acceptLanguageKey, runtime.MetadataPrefix + acceptLanguageKey} {
+		if v := md.Get(key); len(v) > 0 {
+			return NegotiateLocale(strings.Join(v, ","))
+		}
+	}
+	return NegotiateLocale("")
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// localize returns a copy of e with its message rendered from the catalog for locale,
+// or e if there is no message for its code.
+func (e *appError) localize(locale string) *appError {
+	msg, ok := renderMessage(locale, e.Code, e.Params)
+	if !ok {
+		return e
+	}
+	cp := *e
+	cp.rawMessage = e.originalMessage()
+	cp.Message = msg
+	cp.localized = true
+	return &cp
+}
+
+// localizeStatus returns st with its message rendered from the catalog for locale,
+// using the default code of its status.
+func localizeStatus(st *status.Status, locale string) *status.Status {
+	msg, ok := renderMessage(locale, codeForStatus(st.Code()), nil)
+	if !ok {
+		return st
+	}
+	p := st.Proto()
+	p.Message = msg
+	return status.FromProto(p)
+}
+
+// renderMessage renders the message of code for locale, falling back to the default locale.
+func renderMessage(locale string, code string, params map[string]interface{}) (string, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	t, ok := catalog[locale][code]
+	if !ok {
+		t, ok = catalog[getDefaultLocale()][code]
+	}
+	if !ok {
+		return "", false
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, params); err != nil {
+		return "", false
+	}
+	return sb.String(), true
+}
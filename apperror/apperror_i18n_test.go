@@ -0,0 +1,153 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func setupCatalog(t *testing.T) {
+	t.Helper()
+	catalogMu.Lock()
+	old := catalog
+	catalog = map[string]map[string]*template.Template{}
+	catalogMu.Unlock()
+	t.Cleanup(func() {
+		catalogMu.Lock()
+		catalog = old
+		catalogMu.Unlock()
+	})
+
+	if err := RegisterMessages("en", map[string]string{
+		"ERR_USER_NOTFOUND": "User {{.name}} not found",
+		ErrNotFound:         "Not found",
+	}); err != nil {
+		t.Fatalf("RegisterMessages() error = %v", err)
+	}
+	if err := RegisterMessages("vi-VN", map[string]string{
+		"ERR_USER_NOTFOUND": "Không tìm thấy người dùng {{.name}}",
+		ErrInternal:         "Lỗi hệ thống",
+	}); err != nil {
+		t.Fatalf("RegisterMessages() error = %v", err)
+	}
+}
+
+func TestRegisterMessages(t *testing.T) {
+	setupCatalog(t)
+	if err := RegisterMessages("en", map[string]string{"ERR_X": "{{.name"}); err == nil {
+		t.Error("RegisterMessages() with invalid template succeeded")
+	}
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	setupCatalog(t)
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"vi-VN", "vi-vn"},
+		{"vi", "vi-vn"},
+		{"fr-FR, vi;q=0.8, en;q=0.5", "vi-vn"},
+		{"en;q=0.4, vi_VN;q=0.9", "vi-vn"},
+		{"vi;q=0, en-US", "en"},
+		{"fr, *", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			if got := NegotiateLocale(tt.acceptLanguage); got != tt.want {
+				t.Errorf("NegotiateLocale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("default locale", func(t *testing.T) {
+		SetDefaultLocale("vi_VN")
+		defer SetDefaultLocale("en")
+		if got := NegotiateLocale("fr"); got != "vi-vn" {
+			t.Errorf("NegotiateLocale() = %v, want vi-vn", got)
+		}
+		appErr, _ := As(Localize(InternalError(errors.New("disk full")), "fr"))
+		if got := appErr.ErrorMessage(); got != "Lỗi hệ thống" {
+			t.Errorf("Localize() message = %v, want the message of the default locale", got)
+		}
+	})
+}
+
+func TestLocalize(t *testing.T) {
+	setupCatalog(t)
+	err := WithParams(NotFoundWithCode("ERR_USER_NOTFOUND", "user 42 not found"), map[string]interface{}{"name": "42"})
+	tests := []struct {
+		name   string
+		err    error
+		locale string
+		want   string
+	}{
+		{"vietnamese", err, "vi-vn", "Không tìm thấy người dùng 42"},
+		{"english", err, "en", "User 42 not found"},
+		{"fallback to default locale", NotFound("user not found"), "vi-vn", "Not found"},
+		{"no message", BadRequest("invalid"), "vi-vn", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr, _ := As(Localize(tt.err, tt.locale))
+			if got := appErr.ErrorMessage(); got != tt.want {
+				t.Errorf("Localize() message = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if plain := errors.New("plain"); Localize(plain, "en") != plain {
+		t.Error("Localize() changed a plain error")
+	}
+}
+
+func TestWrapGrpcError_localized(t *testing.T) {
+	setupCatalog(t)
+	defer SetInternalErrorLogger(nil)
+	var logged string
+	SetInternalErrorLogger(func(ctx context.Context, r *InternalErrorReport) { logged = r.Message })
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("grpcgateway-accept-language", "vi,en;q=0.5"))
+	tests := []struct {
+		name       string
+		err        error
+		want       string
+		wantLogged string
+	}{
+		{"params", WithParams(NotFoundWithCode("ERR_USER_NOTFOUND", "user 42 not found"), map[string]interface{}{"name": "42"}), "Không tìm thấy người dùng 42", ""},
+		{"internal", InternalError(errors.New("disk full")), "Lỗi hệ thống", "disk full"},
+		{"localized by the handler", Localize(InternalError(errors.New("disk full")), "vi-VN"), "Lỗi hệ thống", "disk full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged = ""
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, tt.err }
+			_, err := WrapGrpcError(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			if msg := status.Convert(err).Message(); !strings.Contains(msg, tt.want) {
+				t.Errorf("WrapGrpcError() message = %v, want %v", msg, tt.want)
+			}
+			if logged != tt.wantLogged {
+				t.Errorf("logged message = %q, want the original message %q", logged, tt.wantLogged)
+			}
+		})
+	}
+}
+
+func TestFormatRestError_localized(t *testing.T) {
+	setupCatalog(t)
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	r.Header.Set("Accept-Language", "en-US")
+	w := httptest.NewRecorder()
+	FormatRestError(context.Background(), nil, nil, w, r, status.Error(codes.NotFound, "rpc error"))
+	if got, want := w.Body.String(), `{"code":"ERR_NOTFOUND","message":"Not found"}`; got != want {
+		t.Errorf("FormatRestError() body = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package apperror
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// ErrorFormat is a body format of FormatRestError.
+type ErrorFormat int
+
+const (
+	// ErrorFormatDefault writes {"code","message"} objects.
+	ErrorFormatDefault ErrorFormat = iota
+	// ErrorFormatProblem writes RFC 9457 (formerly RFC 7807) problem details.
+	ErrorFormatProblem
+)
+
+var (
+	restErrorFormat    atomic.Int32
+	problemTypeBaseURI atomic.Value
+)
+
+// SetRestErrorFormat sets the format of FormatRestError for requests which do not accept
+// application/problem+json explicitly, ErrorFormatDefault by default.
+func SetRestErrorFormat(f ErrorFormat) {
+	restErrorFormat.Store(int32(f))
+}
+
+func getRestErrorFormat() ErrorFormat {
+	return ErrorFormat(restErrorFormat.Load())
+}
+
+// SetProblemTypeBaseURI sets the URI prepended to the apperror code to make the type of problems,
+// e.g. "https://errors.example.com/" gives "https://errors.example.com/ERR_NOTFOUND".
+// Problems have the type "about:blank" if it is empty, which is the default.
+func SetProblemTypeBaseURI(uri string) {
+	problemTypeBaseURI.Store(uri)
+}
+
+func getProblemTypeBaseURI() string {
+	uri, _ := problemTypeBaseURI.Load().(string)
+	return uri
+}
+
+// Problem is a RFC 9457 problem details object with the apperror members as extensions.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	ErrorID   string            `json:"error_id,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   []json.RawMessage `json:"details,omitempty"`
+}
+
+// errorFormat returns the format accepted by r.
+func errorFormat(r *http.Request) ErrorFormat {
+	if r == nil {
+		return getRestErrorFormat()
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(mediaRange); err == nil && mediaType == ProblemContentType {
+				return ErrorFormatProblem
+			}
+		}
+	}
+	return getRestErrorFormat()
+}
+
+// problemFor converts body, written by FormatRestError for the status st, into a problem.
+func problemFor(body []byte, st *status.Status, statusCode int, r *http.Request) []byte {
+	e := Err{}
+	if err := json.Unmarshal(body, &e); err != nil || e.Code == "" {
+		e = Err{Code: codeForStatus(st.Code()), Message: string(body), Details: renderDetails(st)}
+	}
+	p := &Problem{
+		Type:    "about:blank",
+		Title:   http.StatusText(statusCode),
+		Status:  statusCode,
+		Detail:  e.Message,
+		Code:    e.Code,
+		ErrorID: e.ErrorID,
+		Details: e.Details,
+	}
+	if uri := getProblemTypeBaseURI(); uri != "" {
+		p.Type = uri + e.Code
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+		p.RequestID = r.Header.Get(runtime.MetadataHeaderPrefix + requestIDKey)
+		if p.RequestID == "" {
+			p.RequestID = r.Header.Get(requestIDKey)
+		}
+	}
+	b, _ := json.Marshal(p)
+	return b
+}
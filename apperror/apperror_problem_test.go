@@ -0,0 +1,101 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFormatRestError_problem(t *testing.T) {
+	normalized := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs(errorNormalizedFlag, "OK"),
+	})
+	internalErr := &appError{}
+	errors.As(InternalError(errors.New("connection refused")), &internalErr)
+	notFound := &appError{}
+	errors.As(WithResourceInfo(NotFoundWithCode("ERR_USER_NOTFOUND", "user not found"), "user", "users/42", "", ""), &notFound)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		err         error
+		accept      string
+		format      ErrorFormat
+		typeBaseURI string
+		wantType    string
+		wantBody    string
+		wantStatus  int
+	}{
+		{
+			name:        "accept header",
+			ctx:         normalized,
+			err:         notFound.grpcStatus().Err(),
+			accept:      "application/problem+json, application/json;q=0.9",
+			typeBaseURI: "https://errors.example.com/",
+			wantType:    ProblemContentType,
+			wantBody: `{"type":"https://errors.example.com/ERR_USER_NOTFOUND","title":"Not Found","status":404,` +
+				`"detail":"user not found","instance":"/v1/users/42","code":"ERR_USER_NOTFOUND","request_id":"req-1",` +
+				`"details":[{"@type":"type.googleapis.com/google.rpc.ResourceInfo","resourceType":"user","resourceName":"users/42"}]}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:     "configured",
+			ctx:      normalized,
+			err:      internalErr.grpcStatus().Err(),
+			format:   ErrorFormatProblem,
+			wantType: ProblemContentType,
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal error",` +
+				`"instance":"/v1/users/42","code":"ERR_INTERNAL","error_id":"` + internalErr.ErrorID + `","request_id":"req-1"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:     "plain status",
+			ctx:      context.Background(),
+			err:      status.Error(codes.Unavailable, "down"),
+			accept:   ProblemContentType,
+			wantType: ProblemContentType,
+			wantBody: `{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"down",` +
+				`"instance":"/v1/users/42","code":"ERR_UNAVAILABLE","request_id":"req-1"}`,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "default",
+			ctx:        context.Background(),
+			err:        status.Error(codes.Unavailable, "down"),
+			accept:     "application/json",
+			wantType:   "application/json",
+			wantBody:   `{"code":"ERR_UNAVAILABLE","message":"down"}`,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer SetRestErrorFormat(ErrorFormatDefault)
+			defer SetProblemTypeBaseURI("")
+			SetRestErrorFormat(tt.format)
+			SetProblemTypeBaseURI(tt.typeBaseURI)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/42", nil)
+			r.Header.Set("Accept", tt.accept)
+			r.Header.Set(runtime.MetadataHeaderPrefix+requestIDKey, "req-1")
+			w := httptest.NewRecorder()
+			FormatRestError(tt.ctx, nil, nil, w, r, tt.err)
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("FormatRestError() body = %v, want %v", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("FormatRestError() content type = %v, want %v", got, tt.wantType)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("FormatRestError() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package apperror
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// RecoverGrpcPanic is a unary server interceptor converting panics of handlers into internal errors.
+// The panics are logged by the internal error logger with the stack of the panic, whatever the stack capture level.
+func RecoverGrpcPanic(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			method := ""
+			if info != nil {
+				method = info.FullMethod
+			}
+			err, _ = normalize(ctx, method, panicError(p))
+			_ = grpc.SetHeader(ctx, metadata.Pairs(errorNormalizedFlag, "OK"))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// RecoverGrpcStreamPanic is the stream counterpart of RecoverGrpcPanic.
+func RecoverGrpcStreamPanic(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err, _ = normalize(ss.Context(), info.FullMethod, panicError(p))
+			_ = ss.SetHeader(metadata.Pairs(errorNormalizedFlag, "OK"))
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// panicError returns the internal error of the recovered value p. It must be called by the deferred
+// function recovering p, the stack of the panic is still on the goroutine then.
+func panicError(p interface{}) *appError {
+	inner, _ := p.(error)
+	e := newError(codes.Internal, ErrInternal, fmt.Sprintf("panic: %v", p), inner)
+	e.Stack = stackTrace(3)
+	return e
+}
@@ -0,0 +1,111 @@
+package apperror
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// keepTestFrames makes the stack filter keep the frames of the tests, which are in package apperror.
+func keepTestFrames(t *testing.T) {
+	t.Helper()
+	SetStackFilter(func(function string) bool {
+		return strings.HasPrefix(function, "runtime.") || strings.HasPrefix(function, "github.com/ahiho/gocandy/apperror.Recover")
+	})
+	t.Cleanup(func() { SetStackFilter(nil) })
+}
+
+func panickingHandler(context.Context, interface{}) (interface{}, error) {
+	var m map[string]int
+	m["boom"]++
+	return nil, nil
+}
+
+func TestRecoverGrpcPanic(t *testing.T) {
+	keepTestFrames(t)
+	SetStackCapture(StackNever)
+	defer SetStackCapture(StackInternal)
+	var reports []*InternalErrorReport
+	defer SetInternalErrorLogger(nil)
+	SetInternalErrorLogger(func(ctx context.Context, r *InternalErrorReport) { reports = append(reports, r) })
+
+	_, err := RecoverGrpcPanic(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Panic"}, panickingHandler)
+	st := status.Convert(err)
+	if st.Code() != codes.Internal || !strings.Contains(st.Message(), DefaultRedactedMessage) {
+		t.Errorf("RecoverGrpcPanic() = %v, want redacted internal error", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("the internal error logger called %d times, want 1", len(reports))
+	}
+	r := reports[0]
+	if !strings.Contains(r.Message, "assignment to entry in nil map") || len(r.Chain) != 1 {
+		t.Errorf("report = %+v, want the panic", r)
+	}
+	if len(r.Stack) == 0 || !strings.Contains(r.Stack[0], "panickingHandler") {
+		t.Errorf("report stack = %v, want the panicking function first", r.Stack)
+	}
+
+	reports = nil
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	if resp, err := RecoverGrpcPanic(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); resp != "ok" || err != nil {
+		t.Errorf("RecoverGrpcPanic() = %v, %v", resp, err)
+	}
+	if len(reports) != 0 {
+		t.Error("the internal error logger called without panic")
+	}
+}
+
+type panickingHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (panickingHealthServer) Watch(*grpc_health_v1.HealthCheckRequest, grpc_health_v1.Health_WatchServer) error {
+	panic("watch failed")
+}
+
+func TestRecoverGrpcStreamPanic(t *testing.T) {
+	keepTestFrames(t)
+	var reports []*InternalErrorReport
+	defer SetInternalErrorLogger(nil)
+	SetInternalErrorLogger(func(ctx context.Context, r *InternalErrorReport) { reports = append(reports, r) })
+
+	srv := panickingHealthServer{}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		return srv.(grpc_health_v1.HealthServer).Watch(nil, nil)
+	}
+	ss := &fakeServerStream{ctx: context.Background()}
+	err := RecoverGrpcStreamPanic(srv, ss, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("RecoverGrpcStreamPanic() = %v, want internal error", err)
+	}
+	if len(reports) != 1 || reports[0].Message != "panic: watch failed" || reports[0].Method != "/grpc.health.v1.Health/Watch" {
+		t.Fatalf("reports = %+v, want the panic", reports)
+	}
+	if !strings.Contains(reports[0].Stack[0], "panickingHealthServer.Watch") {
+		t.Errorf("report stack = %v, want the panicking function first", reports[0].Stack)
+	}
+	if ss.header.Get(errorNormalizedFlag) == nil {
+		t.Error("RecoverGrpcStreamPanic() did not set the normalized flag")
+	}
+}
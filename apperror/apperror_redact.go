@@ -0,0 +1,145 @@
+package apperror
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDKey = "x-request-id"
+
+// DefaultRedactedMessage is the message clients get instead of the message of internal errors,
+// unless SetRedactedMessage sets another one.
+const DefaultRedactedMessage = "Internal error"
+
+var (
+	redactedMessage     atomic.Value
+	internalErrorLogger atomic.Pointer[func(ctx context.Context, r *InternalErrorReport)]
+)
+
+// SetRedactedMessage sets the message clients get instead of the message of internal errors.
+// An empty message restores DefaultRedactedMessage.
+func SetRedactedMessage(msg string) {
+	redactedMessage.Store(msg)
+}
+
+func getRedactedMessage() string {
+	if msg, _ := redactedMessage.Load().(string); msg != "" {
+		return msg
+	}
+	return DefaultRedactedMessage
+}
+
+// DefaultInternalErrorLogger logs r with the standard logger.
+func DefaultInternalErrorLogger(ctx context.Context, r *InternalErrorReport) {
+	log.Print(r)
+}
+
+// SetInternalErrorLogger sets the function logging the internal errors returned by WrapGrpcError,
+// the clients only get their ErrorID. Set it to log with the logger of the application.
+// A nil logger restores DefaultInternalErrorLogger.
+func SetInternalErrorLogger(logger func(ctx context.Context, r *InternalErrorReport)) {
+	if logger == nil {
+		internalErrorLogger.Store(nil)
+		return
+	}
+	internalErrorLogger.Store(&logger)
+}
+
+func logInternalError(ctx context.Context, r *InternalErrorReport) {
+	if logger := internalErrorLogger.Load(); logger != nil {
+		(*logger)(ctx, r)
+		return
+	}
+	DefaultInternalErrorLogger(ctx, r)
+}
+
+// InternalErrorReport contains what is redacted from an internal error before it is returned to a client.
+type InternalErrorReport struct {
+	ErrorID   string
+	RequestID string
+	Method    string
+	Code      string
+	Message   string
+	// Chain contains the messages of the errors wrapped by the internal error, outermost first.
+	Chain []string
+	Stack []string
+}
+
+func (r *InternalErrorReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "internal error error_id=%s request_id=%s method=%s code=%s: %s",
+		r.ErrorID, r.RequestID, r.Method, r.Code, r.Message)
+	for _, c := range r.Chain {
+		sb.WriteString("\ncaused by: ")
+		sb.WriteString(c)
+	}
+	for _, s := range r.Stack {
+		sb.WriteString("\n\t")
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// ErrorID returns the ID under which the internal error err is logged, or an empty string if err is
+// not an internal apperror.
+func ErrorID(err error) string {
+	appErr := &appError{}
+	if errors.As(err, &appErr) {
+		return appErr.ErrorID
+	}
+	return ""
+}
+
+// isRedacted reports whether errors with status code have their message hidden from clients.
+func isRedacted(code codes.Code) bool {
+	// nolint: exhaustive // Only server failures are redacted.
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+func newErrorID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// report returns the report of e for the request of ctx.
+func (e *appError) report(ctx context.Context, method string) *InternalErrorReport {
+	r := &InternalErrorReport{
+		ErrorID: e.ErrorID,
+		Method:  method,
+		Code:    e.Code,
+		Message: e.originalMessage(),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDKey); len(ids) > 0 {
+			r.RequestID = ids[0]
+		}
+	}
+	for err := e.InnerErr; err != nil; err = errors.Unwrap(err) {
+		r.Chain = append(r.Chain, err.Error())
+	}
+	r.Stack = e.frames()
+	return r
+}
+
+// originalMessage returns the message of e before localization.
+func (e *appError) originalMessage() string {
+	if e.localized {
+		return e.rawMessage
+	}
+	return e.Message
+}
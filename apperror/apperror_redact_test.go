@@ -0,0 +1,90 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestInternalError_redacted(t *testing.T) {
+	err := InternalError(fmt.Errorf("query users: %w", errors.New("dial tcp 10.0.0.1:3306: connection refused")))
+	id := ErrorID(err)
+	if len(id) != 16 {
+		t.Fatalf("ErrorID() = %q, want 16 hex characters", id)
+	}
+	if ErrorID(InternalError(err)) == id {
+		t.Error("ErrorID() is not unique")
+	}
+	if ErrorID(Wrap(err, "list users")) != id {
+		t.Error("Wrap() changed the error ID")
+	}
+	if ErrorID(NotFound("user not found")) != "" {
+		t.Error("ErrorID() of not found error is not empty")
+	}
+
+	got := Err{}
+	if jsonErr := json.Unmarshal([]byte(err.(*appError).ToJSON()), &got); jsonErr != nil {
+		t.Fatalf("ToJSON() is invalid: %v", jsonErr)
+	}
+	want := Err{Code: ErrInternal, Message: DefaultRedactedMessage, ErrorID: id}
+	if got.Code != want.Code || got.Message != want.Message || got.ErrorID != want.ErrorID || got.StackTrace != nil {
+		t.Errorf("ToJSON() = %+v, want %+v", got, want)
+	}
+	SetRedactedMessage("Something went wrong")
+	defer SetRedactedMessage("")
+	if got := err.(*appError).ToJSON(); !strings.Contains(got, `"message":"Something went wrong"`) {
+		t.Errorf("ToJSON() = %v, want the redacted message set by SetRedactedMessage", got)
+	}
+}
+
+func TestWrapGrpcError_redacted(t *testing.T) {
+	var reports []*InternalErrorReport
+	defer SetInternalErrorLogger(nil)
+	SetInternalErrorLogger(func(ctx context.Context, r *InternalErrorReport) {
+		reports = append(reports, r)
+	})
+
+	cause := errors.New("Error 1054: Unknown column 'nme' in 'field list'")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("find user: %w", cause)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDKey, "req-1"))
+	_, err := WrapGrpcError(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetUser"}, handler)
+
+	msg := status.Convert(err).Message()
+	if strings.Contains(msg, "nme") || !strings.Contains(msg, DefaultRedactedMessage) {
+		t.Errorf("WrapGrpcError() message = %v, want redacted", msg)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("the internal error logger called %d times, want 1", len(reports))
+	}
+	r := reports[0]
+	if r.ErrorID == "" || !strings.Contains(msg, r.ErrorID) {
+		t.Errorf("report error ID = %q, message = %v", r.ErrorID, msg)
+	}
+	if r.RequestID != "req-1" || r.Method != "/user.v1.UserService/GetUser" || r.Code != ErrInternal {
+		t.Errorf("report = %+v", r)
+	}
+	if len(r.Chain) != 2 || r.Chain[1] != cause.Error() {
+		t.Errorf("report chain = %v, want 2 errors ending with %v", r.Chain, cause)
+	}
+	if len(r.Stack) == 0 {
+		t.Error("report has no stack")
+	}
+
+	reports = nil
+	handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, BadRequest("invalid name")
+	}
+	_, _ = WrapGrpcError(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	if len(reports) != 0 {
+		t.Errorf("the internal error logger called for bad request")
+	}
+}
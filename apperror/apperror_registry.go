@@ -0,0 +1,239 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	ErrInvalidCode      = errors.New("apperror: invalid code")
+	ErrDuplicateCode    = errors.New("apperror: duplicate code")
+	ErrUnregisteredCode = errors.New("apperror: unregistered code")
+)
+
+// StrictCodes makes errors with codes which are not registered internal errors wrapping ErrUnregisteredCode.
+var StrictCodes atomic.Bool
+
+// CodeInfo describes an apperror code.
+type CodeInfo struct {
+	Code   string
+	Status codes.Code
+	// HTTPStatus is the status of REST responses. If zero, it is the status grpc-gateway maps Status to,
+	// or 500 if that is not in AllowedHTTPErrorStatuses.
+	HTTPStatus  int
+	Description string
+	// Retryable reports whether a request failing with the code may succeed when retried unchanged.
+	Retryable bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]CodeInfo{}
+)
+
+func init() {
+	for _, info := range []CodeInfo{
+		{Code: ErrBadRequest, Status: codes.InvalidArgument, Description: "The request is invalid."},
+		{Code: ErrUnauthorized, Status: codes.Unauthenticated, Description: "The request has no valid credentials."},
+		{Code: ErrForbidden, Status: codes.PermissionDenied, Description: "The caller is not allowed to perform the request."},
+		{Code: ErrNotFound, Status: codes.NotFound, Description: "The resource does not exist."},
+		{Code: ErrInternal, Status: codes.Internal, Description: "An unexpected server error. Report the error ID to support."},
+		{Code: ErrAlreadyExists, Status: codes.AlreadyExists, Description: "The resource already exists."},
+		{Code: ErrConflict, Status: codes.Aborted, Description: "The request conflicts with a concurrent modification.", Retryable: true},
+		{Code: ErrFailedPrecondition, Status: codes.FailedPrecondition, Description: "The resource is not in the state required by the request."},
+		{Code: ErrResourceExhausted, Status: codes.ResourceExhausted, Description: "A quota or rate limit is exceeded.", Retryable: true},
+		{Code: ErrUnavailable, Status: codes.Unavailable, Description: "The service is temporarily unavailable.", Retryable: true},
+		{Code: ErrDeadlineExceeded, Status: codes.DeadlineExceeded, Description: "The request did not complete in time.", Retryable: true},
+		{Code: ErrUnimplemented, Status: codes.Unimplemented, Description: "The operation is not implemented."},
+		{Code: ErrCanceled, Status: codes.Canceled, Description: "The request was canceled by the caller."},
+	} {
+		MustRegisterCode(info)
+	}
+}
+
+// RegisterCode adds a code to the registry.
+func RegisterCode(info CodeInfo) error {
+	if info.Code == "" || info.Status == codes.OK {
+		return fmt.Errorf("%w: %q must have a code and an error status", ErrInvalidCode, info.Code)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[info.Code]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCode, info.Code)
+	}
+	registry[info.Code] = info
+	return nil
+}
+
+// MustRegisterCode is like RegisterCode but panics on errors. It is meant for package initialization.
+func MustRegisterCode(info CodeInfo) {
+	if err := RegisterCode(info); err != nil {
+		panic(err)
+	}
+}
+
+// LookupCode returns the registered info of code.
+func LookupCode(code string) (CodeInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	info, ok := registry[code]
+	return info, ok
+}
+
+// RegisteredCodes returns the registered codes sorted by code.
+func RegisteredCodes() []CodeInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	res := make([]CodeInfo, 0, len(registry))
+	for _, info := range registry {
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Code < res[j].Code })
+	return res
+}
+
+// New returns an error with a registered code, using its status. Unregistered codes give internal errors.
+func New(code string, msg string) error {
+	return makeError(statusOfCode(code), code, msg, nil)
+}
+
+func NewE(code string, msg string, err error) error {
+	return makeError(statusOfCode(code), code, msg, err)
+}
+
+func statusOfCode(code string) codes.Code {
+	if info, ok := LookupCode(code); ok {
+		return info.Status
+	}
+	return codes.Internal
+}
+
+// IsRetryable reports whether the code of err is registered as retryable.
+func IsRetryable(err error) bool {
+	info, ok := LookupCode(CodeOf(err))
+	return ok && info.Retryable
+}
+
+// httpStatus returns the HTTP status of REST responses with the code of info.
+func (info CodeInfo) httpStatus() int {
+	if info.HTTPStatus != 0 {
+		return info.HTTPStatus
+	}
+	return httpStatusFromCode(info.Status)
+}
+
+// checkCode returns the status, code and inner error of an error created with code in strict mode.
+func checkCode(statusCode codes.Code, code string, err error) (codes.Code, string, error) {
+	if !StrictCodes.Load() {
+		return statusCode, code, err
+	}
+	if _, ok := LookupCode(code); ok {
+		return statusCode, code, err
+	}
+	if err == nil {
+		return codes.Internal, ErrInternal, fmt.Errorf("%w: %s", ErrUnregisteredCode, code)
+	}
+	return codes.Internal, ErrInternal, fmt.Errorf("%w: %s: %v", ErrUnregisteredCode, code, err)
+}
+
+type exportedCode struct {
+	Code        string `json:"code"`
+	GRPCStatus  string `json:"grpc_status"`
+	HTTPStatus  int    `json:"http_status"`
+	Description string `json:"description"`
+	Retryable   bool   `json:"retryable"`
+}
+
+func exportedCodes() []exportedCode {
+	infos := RegisteredCodes()
+	res := make([]exportedCode, len(infos))
+	for i, info := range infos {
+		res[i] = exportedCode{
+			Code:        info.Code,
+			GRPCStatus:  info.Status.String(),
+			HTTPStatus:  info.httpStatus(),
+			Description: info.Description,
+			Retryable:   info.Retryable,
+		}
+	}
+	return res
+}
+
+// ExportJSON writes the registered codes as a JSON array.
+func ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(exportedCodes())
+}
+
+// ExportMarkdown writes the registered codes as a Markdown table.
+func ExportMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("| Code | gRPC status | HTTP status | Retryable | Description |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, c := range exportedCodes() {
+		retryable := "no"
+		if c.Retryable {
+			retryable = "yes"
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s | %d | %s | %s |\n",
+			c.Code, c.GRPCStatus, c.HTTPStatus, retryable, strings.ReplaceAll(c.Description, "|", `\|`))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ExportOpenAPI writes OpenAPI 3 components with an ErrorCode schema enumerating the registered codes,
+// the Error schema of FormatRestError and a response for each code.
+func ExportOpenAPI(w io.Writer) error {
+	type object = map[string]interface{}
+
+	exported := exportedCodes()
+	enum := make([]string, len(exported))
+	responses := object{}
+	for i, c := range exported {
+		enum[i] = c.Code
+		responses[c.Code] = object{
+			"description": c.Description,
+			"content": object{
+				"application/json": object{
+					"schema":  object{"$ref": "#/components/schemas/Error"},
+					"example": object{"code": c.Code, "message": c.Description},
+				},
+			},
+			"x-grpc-status": c.GRPCStatus,
+			"x-http-status": c.HTTPStatus,
+			"x-retryable":   c.Retryable,
+		}
+	}
+	doc := object{
+		"components": object{
+			"schemas": object{
+				"ErrorCode": object{"type": "string", "enum": enum},
+				"Error": object{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": object{
+						"code":     object{"$ref": "#/components/schemas/ErrorCode"},
+						"message":  object{"type": "string"},
+						"error_id": object{"type": "string"},
+						"details":  object{"type": "array", "items": object{"type": "object"}},
+					},
+				},
+			},
+			"responses": responses,
+		},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,151 @@
+package apperror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func setupRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	old := make(map[string]CodeInfo, len(registry))
+	for k, v := range registry {
+		old[k] = v
+	}
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = old
+		registryMu.Unlock()
+		StrictCodes.Store(false)
+	})
+
+	MustRegisterCode(CodeInfo{Code: "ERR_USER_NOTFOUND", Status: codes.NotFound, Description: "The user does not exist."})
+	MustRegisterCode(CodeInfo{Code: "ERR_PAYMENT_REQUIRED", Status: codes.FailedPrecondition, HTTPStatus: http.StatusPaymentRequired,
+		Description: "The plan | quota is used up."})
+}
+
+func TestRegisterCode(t *testing.T) {
+	setupRegistry(t)
+	if err := RegisterCode(CodeInfo{Code: ErrNotFound, Status: codes.NotFound}); !errors.Is(err, ErrDuplicateCode) {
+		t.Errorf("RegisterCode() error = %v, want %v", err, ErrDuplicateCode)
+	}
+	if err := RegisterCode(CodeInfo{Code: "ERR_OK"}); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("RegisterCode() error = %v, want %v", err, ErrInvalidCode)
+	}
+	if info, ok := LookupCode("ERR_USER_NOTFOUND"); !ok || info.Status != codes.NotFound {
+		t.Errorf("LookupCode() = %v, %v", info, ok)
+	}
+
+	if got := StatusOf(New("ERR_USER_NOTFOUND", "user not found")); got != codes.NotFound {
+		t.Errorf("New() status = %v, want %v", got, codes.NotFound)
+	}
+	if got := StatusOf(New("ERR_UNKNOWN", "unknown")); got != codes.Internal {
+		t.Errorf("New() status = %v, want %v", got, codes.Internal)
+	}
+	if !IsRetryable(Unavailable("down")) || IsRetryable(NotFound("missing")) || IsRetryable(errors.New("plain")) {
+		t.Error("IsRetryable() is wrong")
+	}
+}
+
+func TestStrictCodes(t *testing.T) {
+	setupRegistry(t)
+	StrictCodes.Store(true)
+
+	if err := NotFoundWithCode("ERR_USER_NOTFOUND", "user not found"); CodeOf(err) != "ERR_USER_NOTFOUND" {
+		t.Errorf("NotFoundWithCode() = %v, want a registered code", err)
+	}
+	cause := errors.New("missing row")
+	err := NotFoundWithCodeE("ERR_TYPO", "user not found", cause)
+	if CodeOf(err) != ErrInternal || StatusOf(err) != codes.Internal {
+		t.Errorf("NotFoundWithCodeE() = %v, want internal error", err)
+	}
+	if !errors.Is(err, ErrUnregisteredCode) || !strings.Contains(errors.Unwrap(err).Error(), "ERR_TYPO: missing row") {
+		t.Errorf("NotFoundWithCodeE() inner = %v, want %v", errors.Unwrap(err), ErrUnregisteredCode)
+	}
+}
+
+func TestFormatRestError_registeredHTTPStatus(t *testing.T) {
+	setupRegistry(t)
+	appErr := &appError{}
+	errors.As(FailedPreconditionWithCode("ERR_PAYMENT_REQUIRED", "upgrade your plan"), &appErr)
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs(errorNormalizedFlag, "OK"),
+	})
+	w := httptest.NewRecorder()
+	FormatRestError(ctx, nil, nil, w, nil, appErr.grpcStatus().Err())
+	if w.Code != http.StatusPaymentRequired {
+		t.Errorf("FormatRestError() status = %v, want %v", w.Code, http.StatusPaymentRequired)
+	}
+}
+
+func TestExport(t *testing.T) {
+	setupRegistry(t)
+
+	var buf bytes.Buffer
+	if err := ExportJSON(&buf); err != nil {
+		t.Fatalf("ExportJSON() error = %v", err)
+	}
+	exported := []exportedCode{}
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("ExportJSON() is invalid: %v", err)
+	}
+	want := map[string]exportedCode{
+		"ERR_CANCELED":         {Code: "ERR_CANCELED", GRPCStatus: "Canceled", HTTPStatus: 408, Description: "The request was canceled by the caller."},
+		"ERR_PAYMENT_REQUIRED": {Code: "ERR_PAYMENT_REQUIRED", GRPCStatus: "FailedPrecondition", HTTPStatus: 402, Description: "The plan | quota is used up."},
+		"ERR_UNAVAILABLE":      {Code: "ERR_UNAVAILABLE", GRPCStatus: "Unavailable", HTTPStatus: 503, Description: "The service is temporarily unavailable.", Retryable: true},
+	}
+	for _, c := range exported {
+		if w, ok := want[c.Code]; ok && c != w {
+			t.Errorf("ExportJSON() %s = %+v, want %+v", c.Code, c, w)
+		}
+	}
+	if len(exported) != 15 || exported[0].Code != ErrAlreadyExists {
+		t.Errorf("ExportJSON() = %+v, want 15 sorted codes", exported)
+	}
+
+	buf.Reset()
+	if err := ExportMarkdown(&buf); err != nil {
+		t.Fatalf("ExportMarkdown() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "| `ERR_PAYMENT_REQUIRED` | FailedPrecondition | 402 | no | The plan \\| quota is used up. |\n") {
+		t.Errorf("ExportMarkdown() = %v", buf.String())
+	}
+
+	buf.Reset()
+	if err := ExportOpenAPI(&buf); err != nil {
+		t.Fatalf("ExportOpenAPI() error = %v", err)
+	}
+	doc := struct {
+		Components struct {
+			Schemas struct {
+				ErrorCode struct {
+					Enum []string `json:"enum"`
+				}
+			} `json:"schemas"`
+			Responses map[string]struct {
+				Description string `json:"description"`
+				Retryable   bool   `json:"x-retryable"`
+			} `json:"responses"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("ExportOpenAPI() is invalid: %v", err)
+	}
+	if len(doc.Components.Schemas.ErrorCode.Enum) != 15 {
+		t.Errorf("ExportOpenAPI() enum = %v, want 15 codes", doc.Components.Schemas.ErrorCode.Enum)
+	}
+	if r := doc.Components.Responses[ErrConflict]; !r.Retryable || r.Description == "" {
+		t.Errorf("ExportOpenAPI() response %s = %+v", ErrConflict, r)
+	}
+}
@@ -0,0 +1,116 @@
+package apperror
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	pkgerrors "github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+const stackDepth = 32
+
+// StackCapture controls which errors capture the stack of their creation.
+type StackCapture int32
+
+const (
+	// StackInternal captures the stacks of internal errors, and of all errors if EnableStackTrace is set.
+	StackInternal StackCapture = iota
+	// StackNever captures no stacks.
+	StackNever
+	// StackAlways captures the stacks of all errors.
+	StackAlways
+)
+
+var (
+	stackCapture atomic.Int32
+	stackFilter  atomic.Pointer[func(function string) bool]
+)
+
+// DefaultStackFilter leaves the frames of the Go runtime, gRPC, grpc-gateway and apperror out of rendered stacks.
+func DefaultStackFilter(function string) bool {
+	for _, prefix := range []string{
+		"runtime.",
+		"google.golang.org/grpc.",
+		"google.golang.org/grpc/",
+		"github.com/grpc-ecosystem/grpc-gateway/",
+		"github.com/ahiho/gocandy/apperror.",
+	} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetStackFilter sets the function reporting whether the frames of function are left out of rendered
+// stacks. A nil filter restores DefaultStackFilter.
+func SetStackFilter(filter func(function string) bool) {
+	if filter == nil {
+		stackFilter.Store(nil)
+		return
+	}
+	stackFilter.Store(&filter)
+}
+
+func filterFrame(function string) bool {
+	if filter := stackFilter.Load(); filter != nil {
+		return (*filter)(function)
+	}
+	return DefaultStackFilter(function)
+}
+
+// SetStackCapture sets which errors capture their stack, StackInternal by default.
+// Capturing stacks is not free, the stacks are only logged for internal errors.
+func SetStackCapture(c StackCapture) {
+	stackCapture.Store(int32(c))
+}
+
+func captureStack(statusCode codes.Code) bool {
+	switch StackCapture(stackCapture.Load()) {
+	case StackNever:
+		return false
+	case StackAlways:
+		return true
+	default:
+		return isRedacted(statusCode) || EnableStackTrace.Load()
+	}
+}
+
+// stackTrace returns the stack of the caller, skipping skip frames as runtime.Callers.
+func stackTrace(skip int) pkgerrors.StackTrace {
+	var pcs [stackDepth]uintptr
+	n := runtime.Callers(skip, pcs[:]) - 1
+	if n <= 0 {
+		return nil
+	}
+	f := make([]pkgerrors.Frame, n)
+	for i := 0; i < n; i++ {
+		f[i] = pkgerrors.Frame(pcs[i])
+	}
+	return f
+}
+
+// frames renders the stack of e without the frames left out by the stack filter.
+func (e *appError) frames() []string {
+	if len(e.Stack) == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, len(e.Stack))
+	for i, f := range e.Stack {
+		pcs[i] = uintptr(f)
+	}
+	res := []string{}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !filterFrame(f.Function) {
+			res = append(res, fmt.Sprintf("%s\n\t%s:%d", f.Function, f.File, f.Line))
+		}
+		if !more {
+			return res
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package apperror
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetStackCapture(t *testing.T) {
+	defer SetStackCapture(StackInternal)
+	tests := []struct {
+		name         string
+		capture      StackCapture
+		enableTrace  bool
+		wantNotFound bool
+		wantInternal bool
+	}{
+		{"internal", StackInternal, false, false, true},
+		{"internal with stack traces", StackInternal, true, true, true},
+		{"never", StackNever, true, false, false},
+		{"always", StackAlways, false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStackCapture(tt.capture)
+			EnableStackTrace.Store(tt.enableTrace)
+			defer EnableStackTrace.Store(false)
+			if got := len(NotFound("missing").(*appError).Stack) > 0; got != tt.wantNotFound {
+				t.Errorf("NotFound() has stack = %v, want %v", got, tt.wantNotFound)
+			}
+			if got := len(InternalError(errors.New("boom")).(*appError).Stack) > 0; got != tt.wantInternal {
+				t.Errorf("InternalError() has stack = %v, want %v", got, tt.wantInternal)
+			}
+		})
+	}
+}
+
+func Test_appError_frames(t *testing.T) {
+	err := InternalError(errors.New("boom")).(*appError)
+	frames := err.frames()
+	if len(frames) == 0 {
+		t.Fatal("frames() is empty")
+	}
+	for _, f := range frames {
+		if strings.HasPrefix(f, "runtime.") || strings.HasPrefix(f, "github.com/ahiho/gocandy/apperror.") {
+			t.Errorf("frames() contains %v", f)
+		}
+	}
+}
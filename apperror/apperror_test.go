@@ -2,10 +2,12 @@ package apperror
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func Test_appError_Error(t *testing.T) {
@@ -527,3 +529,124 @@ func TestInternalError(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusConstructors(t *testing.T) {
+	inner := errors.New("inner")
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantCode   string
+		wantInner  error
+	}{
+		{"already exists", AlreadyExists("exists"), codes.AlreadyExists, ErrAlreadyExists, nil},
+		{"already exists with code", AlreadyExistsWithCodeE("EMAIL_TAKEN", "exists", inner), codes.AlreadyExists, "EMAIL_TAKEN", inner},
+		{"conflict", Conflict("changed"), codes.Aborted, ErrConflict, nil},
+		{"conflict with code", ConflictWithCode("STALE", "changed"), codes.Aborted, "STALE", nil},
+		{"failed precondition", FailedPrecondition("not empty"), codes.FailedPrecondition, ErrFailedPrecondition, nil},
+		{"resource exhausted", ResourceExhausted("slow down"), codes.ResourceExhausted, ErrResourceExhausted, nil},
+		{"resource exhausted with code", ResourceExhaustedWithCodeE("QUOTA", "quota", inner), codes.ResourceExhausted, "QUOTA", inner},
+		{"unavailable", Unavailable("down"), codes.Unavailable, ErrUnavailable, nil},
+		{"deadline exceeded", DeadlineExceeded("slow"), codes.DeadlineExceeded, ErrDeadlineExceeded, nil},
+		{"unimplemented", Unimplemented("todo"), codes.Unimplemented, ErrUnimplemented, nil},
+		{"unimplemented with code", UnimplementedWithCode("V2_ONLY", "todo"), codes.Unimplemented, "V2_ONLY", nil},
+		{"canceled", CanceledWithCodeE(ErrCanceled, "canceled", inner), codes.Canceled, ErrCanceled, inner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appE := &appError{}
+			if !errors.As(tt.err, &appE) {
+				t.Fatalf("%v is not an apperror", tt.err)
+			}
+			if appE.Status != tt.wantStatus || appE.Code != tt.wantCode || appE.InnerErr != tt.wantInner {
+				t.Errorf("got status=%v code=%v inner=%v, want status=%v code=%v inner=%v",
+					appE.Status, appE.Code, appE.InnerErr, tt.wantStatus, tt.wantCode, tt.wantInner)
+			}
+		})
+	}
+}
+
+func Test_appError_Unwrap(t *testing.T) {
+	inner := errors.New("record not found")
+	err := fmt.Errorf("get user: %w", NotFoundWithCodeE("USER_NOT_FOUND", "user not found", inner))
+
+	if !errors.Is(err, inner) {
+		t.Errorf("errors.Is(%v, %v) = false, want true", err, inner)
+	}
+	if !errors.Is(err, NotFoundWithCode("USER_NOT_FOUND", "other message")) {
+		t.Errorf("errors.Is() with same code = false, want true")
+	}
+	if errors.Is(err, NotFound("user not found")) {
+		t.Errorf("errors.Is() with other code = true, want false")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantStatus codes.Code
+	}{
+		{"nil", nil, "", codes.OK},
+		{"apperror", fmt.Errorf("wrapped: %w", Conflict("changed")), ErrConflict, codes.Aborted},
+		{"grpc status", status.Error(codes.NotFound, "missing"), ErrNotFound, codes.NotFound},
+		{"other", errors.New("failed"), ErrInternal, codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.wantCode {
+				t.Errorf("CodeOf() = %v, want %v", got, tt.wantCode)
+			}
+			if got := StatusOf(tt.err); got != tt.wantStatus {
+				t.Errorf("StatusOf() = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	SetStackCapture(StackAlways)
+	defer SetStackCapture(StackInternal)
+	inner := errors.New("duplicate entry")
+	base := AlreadyExistsWithCodeE("EMAIL_TAKEN", "email is taken", inner)
+	tests := []struct {
+		name       string
+		err        error
+		wantErr    string
+		wantStatus codes.Code
+	}{
+		{
+			name:       "apperror",
+			err:        Wrapf(base, "create user %v", 1),
+			wantErr:    "apperror:code=EMAIL_TAKEN;msg=create user 1: email is taken",
+			wantStatus: codes.AlreadyExists,
+		},
+		{
+			name:       "other error",
+			err:        Wrap(inner, "create user"),
+			wantErr:    "apperror:code=ERR_INTERNAL;msg=create user: duplicate entry",
+			wantStatus: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Error() != tt.wantErr {
+				t.Errorf("Wrap() error = %v, want %v", tt.err, tt.wantErr)
+			}
+			if StatusOf(tt.err) != tt.wantStatus {
+				t.Errorf("Wrap() status = %v, want %v", StatusOf(tt.err), tt.wantStatus)
+			}
+			if !errors.Is(tt.err, inner) {
+				t.Errorf("Wrap() lost %v", inner)
+			}
+			appErr, ok := As(tt.err)
+			if !ok || len(appErr.(*appError).Stack) == 0 {
+				t.Errorf("Wrap() has no stack")
+			}
+		})
+	}
+	if Wrap(nil, "nothing") != nil {
+		t.Error("Wrap(nil) != nil")
+	}
+}
@@ -0,0 +1,63 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/status"
+)
+
+// Translator converts an error of a library into an apperror. It returns nil for errors it does not handle.
+// Packages translate their own errors by registering a Translator in their init function.
+// The translators of library errors are registered explicitly, e.g. by sqlerror.Register for gorm and MySQL.
+type Translator func(err error) error
+
+var (
+	translatorsMu sync.RWMutex
+	translators   = []Translator{
+		translateContext,
+	}
+)
+
+// RegisterTranslator adds t to the translators used by Translate.
+// Translators registered later take precedence over earlier ones and the built-in translators.
+func RegisterTranslator(t Translator) {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+	translators = append(translators, t)
+}
+
+// Translate converts err into an apperror using the registered translators.
+// Apperrors, gRPC status errors and nil are returned unchanged, errors no translator handles
+// become internal errors.
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := As(err); ok {
+		return err
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	translatorsMu.RLock()
+	defer translatorsMu.RUnlock()
+	for i := len(translators) - 1; i >= 0; i-- {
+		if appErr := translators[i](err); appErr != nil {
+			return appErr
+		}
+	}
+	return InternalError(err)
+}
+
+func translateContext(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceededWithCodeE(ErrDeadlineExceeded, "deadline exceeded", err)
+	case errors.Is(err, context.Canceled):
+		return CanceledWithCodeE(ErrCanceled, "request canceled", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTranslate(t *testing.T) {
+	errCustom := errors.New("custom")
+	RegisterTranslator(func(err error) error {
+		if errors.Is(err, errCustom) {
+			return ForbiddenWithCodeE("CUSTOM", "custom", err)
+		}
+		return nil
+	})
+
+	notFound := NotFound("user not found")
+	grpcErr := status.Error(codes.Unavailable, "down")
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantStatus codes.Code
+	}{
+		{"deadline", context.DeadlineExceeded, ErrDeadlineExceeded, codes.DeadlineExceeded},
+		{"canceled", fmt.Errorf("query: %w", context.Canceled), ErrCanceled, codes.Canceled},
+		{"registered", errCustom, "CUSTOM", codes.PermissionDenied},
+		{"unknown", errors.New("failed"), ErrInternal, codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Translate(tt.err)
+			if CodeOf(got) != tt.wantCode || StatusOf(got) != tt.wantStatus {
+				t.Errorf("Translate() = %v (%v), want code %v (%v)", got, StatusOf(got), tt.wantCode, tt.wantStatus)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("Translate() = %v, lost %v", got, tt.err)
+			}
+		})
+	}
+
+	for _, err := range []error{nil, notFound, grpcErr} {
+		if got := Translate(err); got != err {
+			t.Errorf("Translate(%v) = %v, want unchanged", err, got)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+module github.com/ahiho/gocandy/apperror/sqlerror
+
+go 1.19
+
+require (
+	github.com/ahiho/gocandy/apperror v0.0.0-20261019111112-094aabd0d98a
+	github.com/ahiho/gocandy/fieldmask v0.0.0-20261019100950-41d50864a1d0
+	github.com/ahiho/gocandy/filter v0.0.0-20261019100950-41d50864a1d0
+	github.com/ahiho/gocandy/listing v0.0.0-20261019100950-41d50864a1d0
+	github.com/go-sql-driver/mysql v1.6.0
+	gorm.io/gorm v1.23.10
+)
+
+require (
+	github.com/ahiho/gocandy/gormx/model v0.0.0-20220627170231-e8e96d96368c // indirect
+	github.com/ahiho/gocandy/resource v0.0.0-20220627063417-90993ef64e79 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.11.3 // indirect
+	github.com/iancoleman/strcase v0.2.0 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/net v0.0.0-20220706163947-c90051bbdb60 // indirect
+	golang.org/x/sys v0.0.0-20220610221304-9f5ed59c137d // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
+	google.golang.org/grpc v1.49.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
@@ -0,0 +1,84 @@
+// Package sqlerror translates the errors of gorm, the MySQL driver and the filter, listing and
+// fieldmask packages into apperrors. It is a separate module, so apperror does not depend on them.
+// Services register the translators once at startup:
+//
+//	sqlerror.Register()
+package sqlerror
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/ahiho/gocandy/apperror"
+	"github.com/ahiho/gocandy/fieldmask"
+	filter "github.com/ahiho/gocandy/filter/adapter/sql"
+	"github.com/ahiho/gocandy/listing"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQL error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrDupEntry         = 1062
+	mysqlErrLockWaitTimeout  = 1205
+	mysqlErrDeadlock         = 1213
+	mysqlErrNoReferencedRow  = 1216
+	mysqlErrRowIsReferenced  = 1217
+	mysqlErrDataTooLong      = 1406
+	mysqlErrRowIsReferenced2 = 1451
+	mysqlErrNoReferencedRow2 = 1452
+)
+
+// Register registers TranslateGorm, TranslateMySQL and TranslateListing with apperror.RegisterTranslator.
+func Register() {
+	apperror.RegisterTranslator(TranslateGorm)
+	apperror.RegisterTranslator(TranslateMySQL)
+	apperror.RegisterTranslator(TranslateListing)
+}
+
+// TranslateGorm translates gorm.ErrRecordNotFound into a not found error.
+func TranslateGorm(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFoundWithCodeE(apperror.ErrNotFound, "resource not found", err)
+	}
+	return nil
+}
+
+// TranslateMySQL translates connection errors and the MySQL errors caused by requests, e.g. duplicate entries
+// and foreign key violations. The messages do not contain the MySQL messages, which show the schema.
+func TranslateMySQL(err error) error {
+	if errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) {
+		return apperror.UnavailableWithCodeE(apperror.ErrUnavailable, "database unavailable", err)
+	}
+
+	mysqlErr := &mysql.MySQLError{}
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+	switch mysqlErr.Number {
+	case mysqlErrDupEntry:
+		return apperror.AlreadyExistsWithCodeE(apperror.ErrAlreadyExists, "resource already exists", err)
+	case mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2:
+		return apperror.FailedPreconditionWithCodeE(apperror.ErrFailedPrecondition, "resource is still referenced", err)
+	case mysqlErrNoReferencedRow, mysqlErrNoReferencedRow2:
+		return apperror.FailedPreconditionWithCodeE(apperror.ErrFailedPrecondition, "referenced resource does not exist", err)
+	case mysqlErrDeadlock, mysqlErrLockWaitTimeout:
+		return apperror.ConflictWithCodeE(apperror.ErrConflict, "concurrent modification, retry the request", err)
+	case mysqlErrDataTooLong:
+		return apperror.BadRequestWithCodeE(apperror.ErrBadRequest, "value too long", err)
+	}
+	return nil
+}
+
+// TranslateListing translates the errors of the filter, listing and fieldmask packages,
+// which are caused by invalid requests. Their messages do not contain internals.
+func TranslateListing(err error) error {
+	switch {
+	case errors.Is(err, filter.ErrInvalidQuery),
+		errors.Is(err, listing.ErrPageToken),
+		errors.Is(err, listing.ErrInvalidPageSize),
+		errors.Is(err, fieldmask.ErrTooManyFields):
+		return apperror.BadRequestWithCodeE(apperror.ErrBadRequest, err.Error(), err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package sqlerror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ahiho/gocandy/apperror"
+	"github.com/ahiho/gocandy/fieldmask"
+	"github.com/ahiho/gocandy/listing"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestRegister(t *testing.T) {
+	Register()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"gorm not found", fmt.Errorf("get user: %w", gorm.ErrRecordNotFound), apperror.ErrNotFound},
+		{"duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'email'"}, apperror.ErrAlreadyExists},
+		{"foreign key", &mysql.MySQLError{Number: 1451}, apperror.ErrFailedPrecondition},
+		{"deadlock", &mysql.MySQLError{Number: 1213}, apperror.ErrConflict},
+		{"other mysql error", &mysql.MySQLError{Number: 1146, Message: "Table 'users' doesn't exist"}, apperror.ErrInternal},
+		{"bad connection", mysql.ErrInvalidConn, apperror.ErrUnavailable},
+		{"page token", fmt.Errorf("%w: bad json", listing.ErrPageToken), apperror.ErrBadRequest},
+		{"field mask", fieldmask.ErrTooManyFields, apperror.ErrBadRequest},
+		{"other", errors.New("failed"), apperror.ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := apperror.Translate(tt.err)
+			if got := apperror.CodeOf(err); got != tt.want {
+				t.Errorf("Translate() code = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Translate() = %v, lost %v", err, tt.err)
+			}
+		})
+	}
+}
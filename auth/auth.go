@@ -4,18 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"sync"
 	"time"
 
+	firebaseAuth "firebase.google.com/go/v4/auth"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 type Option struct {
+	// JwtSecret signs and verifies HS256 tokens without kid header.
 	JwtSecret       string
 	DefaultDuration *time.Duration
+	// SigningKey signs the created tokens instead of JwtSecret. Services which only verify tokens leave it nil.
+	SigningKey *Key
+	// VerificationKeys verify tokens besides the signing key, e.g. the previous keys during a rotation.
+	VerificationKeys []*Key
+	// JWKSURL is the URL of a JWKS document with further verification keys.
+	JWKSURL string
+	// RefreshDuration is the lifetime of refresh tokens, DefaultRefreshDuration by default.
+	RefreshDuration *time.Duration
+	// AccessDuration is the lifetime of the access tokens created with a refresh token by
+	// CreateJWTWithRefresh and RefreshJWT, DefaultAccessDuration by default.
+	AccessDuration *time.Duration
+	// RevocationStore enables the revocation of tokens, checked by VerifyJWTToken.
+	RevocationStore RevocationStore
+	// RefreshTokenStore enables refresh tokens.
+	RefreshTokenStore RefreshTokenStore
+	// TokenValidators run on the claims of valid tokens, see AddTokenValidator.
+	TokenValidators []TokenValidator
+	// APIKeys are accepted by VerifyAPIKey, see AddAPIKey.
+	APIKeys []string
+	// Issuer is the iss claim of the created tokens. When set, tokens of other issuers are rejected.
+	Issuer string
+	// Audience is the aud claim of the created tokens. When set, tokens for none of them are rejected.
+	Audience []string
+	// Leeway is the clock skew allowed when checking the exp, nbf and iat claims.
+	Leeway time.Duration
 }
 
 type claimKey struct {
@@ -23,10 +51,15 @@ type claimKey struct {
 }
 
 type TokenClaims struct {
-	UID    int64
-	Sub    string
-	Iat    int64
-	Exp    int64
+	UID int64
+	Sub string
+	Iss string
+	Aud []string
+	Jti string
+	Iat int64
+	Nbf int64
+	Exp int64
+	// Claims are all claims of the token, numbers are float64. Use ClaimsAs to decode them into a struct.
 	Claims map[string]interface{}
 }
 
@@ -39,8 +72,13 @@ type CreateTokenOption struct {
 
 type JWTToken struct {
 	AccessToken string
-	Iat         int64
-	Exp         int64
+	// ID is the jti claim of the access token.
+	ID  string
+	Iat int64
+	Exp int64
+	// RefreshToken is set by CreateJWTWithRefresh and RefreshJWT, it expires at RefreshExp.
+	RefreshToken string
+	RefreshExp   int64
 }
 
 const AnonymousUserID = "Anonymous"
@@ -50,6 +88,7 @@ type TokenValidator = func(context.Context, *TokenClaims) error
 var (
 	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
 	ErrTokenInvalid            = errors.New("invalid jwt token")
+	ErrNoKey                   = errors.New("jwt secret or key is required")
 
 	User = claimKey{
 		Key: "auth.user",
@@ -57,36 +96,180 @@ var (
 	Anonymous = TokenClaims{Sub: AnonymousUserID}
 )
 
-var (
-	jwtSecret       []byte
-	defaultDuration time.Duration
-	tokenValidators []TokenValidator
-)
+// Authenticator issues and verifies tokens with its own keys, stores and validators.
+// The package functions use a default Authenticator configured by Init.
+type Authenticator struct {
+	mu                sync.RWMutex
+	defaultDuration   time.Duration
+	refreshDuration   time.Duration
+	accessDuration    time.Duration
+	signingKey        *Key
+	verificationKeys  []*Key
+	remoteKeys        *RemoteKeySet
+	optionValidators  []TokenValidator
+	tokenValidators   []TokenValidator
+	revocationStore   RevocationStore
+	refreshTokenStore RefreshTokenStore
+	optionAPIKeys     []string
+	apiKeys           []string
+	firAuth           *firebaseAuth.Client
+	issuer            string
+	audience          []string
+	leeway            time.Duration
+}
+
+var defaultAuthenticator = &Authenticator{refreshDuration: DefaultRefreshDuration, accessDuration: DefaultAccessDuration}
+
+// Default returns the Authenticator used by the package functions.
+func Default() *Authenticator {
+	return defaultAuthenticator
+}
+
+// New returns an Authenticator configured with op.
+func New(op Option) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.Init(op); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
 
 func Init(op Option) error {
-	if len(op.JwtSecret) == 0 {
-		return errors.New("jwt secret is required")
+	return defaultAuthenticator.Init(op)
+}
+
+// Init replaces the keys, durations, stores, token validators and API keys of a with op, so calling it
+// again does not accumulate them. The validators and keys added with AddTokenValidator and AddAPIKey,
+// before or after Init, and the Firebase client are kept.
+func (a *Authenticator) Init(op Option) error {
+	if len(op.JwtSecret) == 0 && op.SigningKey == nil && len(op.VerificationKeys) == 0 && op.JWKSURL == "" {
+		return ErrNoKey
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.signingKey = op.SigningKey
+	a.verificationKeys = nil
+	if a.signingKey != nil {
+		a.verificationKeys = append(a.verificationKeys, a.signingKey)
+	}
+	if len(op.JwtSecret) > 0 {
+		secretKey := NewHMACKey("", []byte(op.JwtSecret))
+		if a.signingKey == nil {
+			a.signingKey = secretKey
+		}
+		a.verificationKeys = append(a.verificationKeys, secretKey)
+	}
+	a.verificationKeys = append(a.verificationKeys, op.VerificationKeys...)
+	a.remoteKeys = nil
+	if op.JWKSURL != "" {
+		a.remoteKeys = NewRemoteKeySet(op.JWKSURL)
 	}
-	jwtSecret = []byte(op.JwtSecret)
+
 	if op.DefaultDuration != nil && *op.DefaultDuration > 0 {
-		defaultDuration = *op.DefaultDuration
+		a.defaultDuration = *op.DefaultDuration
+	} else {
+		a.defaultDuration = time.Hour * 24 * 365 // 1 year
+	}
+	if op.RefreshDuration != nil && *op.RefreshDuration > 0 {
+		a.refreshDuration = *op.RefreshDuration
+	} else {
+		a.refreshDuration = DefaultRefreshDuration
+	}
+	if op.AccessDuration != nil && *op.AccessDuration > 0 {
+		a.accessDuration = *op.AccessDuration
 	} else {
-		defaultDuration = time.Hour * 24 * 365 // 1 year
+		a.accessDuration = DefaultAccessDuration
 	}
+	a.issuer = op.Issuer
+	a.audience = append([]string(nil), op.Audience...)
+	a.leeway = op.Leeway
+	a.revocationStore = op.RevocationStore
+	a.refreshTokenStore = op.RefreshTokenStore
+	a.optionValidators = append([]TokenValidator(nil), op.TokenValidators...)
+	a.optionAPIKeys = append([]string(nil), op.APIKeys...)
 	return nil
 }
 
+func AddTokenValidator(validator TokenValidator) {
+	defaultAuthenticator.AddTokenValidator(validator)
+}
+
+// AddTokenValidator adds a validator run by VerifyJWTToken on the claims of valid tokens.
+func (a *Authenticator) AddTokenValidator(validator TokenValidator) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokenValidators = append(a.tokenValidators[:len(a.tokenValidators):len(a.tokenValidators)], validator)
+}
+
+func AddVerificationKey(key *Key) {
+	defaultAuthenticator.AddVerificationKey(key)
+}
+
+// AddVerificationKey adds a key verifying tokens, replacing the key with the same ID.
+func (a *Authenticator) AddVerificationKey(key *Key) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make([]*Key, 0, len(a.verificationKeys)+1)
+	for _, k := range a.verificationKeys {
+		if k.ID != key.ID {
+			keys = append(keys, k)
+		}
+	}
+	a.verificationKeys = append(keys, key)
+}
+
+func RemoveVerificationKey(id string) {
+	defaultAuthenticator.RemoveVerificationKey(id)
+}
+
+// RemoveVerificationKey removes the key with the ID from the verification keys, e.g. at the end of a rotation.
+func (a *Authenticator) RemoveVerificationKey(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make([]*Key, 0, len(a.verificationKeys))
+	for _, k := range a.verificationKeys {
+		if k.ID != id {
+			keys = append(keys, k)
+		}
+	}
+	a.verificationKeys = keys
+}
+
 func CreateJWT(op *CreateTokenOption) (token *JWTToken, err error) {
-	t := jwt.New(jwt.SigningMethodHS256)
-	clms := t.Claims.(jwt.MapClaims)
-	now := time.Now()
-	iat := now.Unix()
-	var exp int64
+	return defaultAuthenticator.CreateJWT(op)
+}
+
+func (a *Authenticator) CreateJWT(op *CreateTokenOption) (token *JWTToken, err error) {
+	a.mu.RLock()
+	duration := a.defaultDuration
+	a.mu.RUnlock()
 	if op.ExpireIn != nil && *op.ExpireIn > 0 {
-		exp = now.Add(*op.ExpireIn).Unix()
-	} else {
-		exp = now.Add(defaultDuration).Unix()
+		duration = *op.ExpireIn
 	}
+	clms, iat, exp := a.newClaims(op, duration)
+
+	jwtToken, err := a.signClaims(clms)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTToken{
+		AccessToken: jwtToken,
+		ID:          clms["jti"].(string),
+		Iat:         iat,
+		Exp:         exp,
+	}, nil
+}
+
+// newClaims returns the claims of a new token with a random ID, valid for duration.
+func (a *Authenticator) newClaims(op *CreateTokenOption, duration time.Duration) (clms jwt.MapClaims, iat int64, exp int64) {
+	a.mu.RLock()
+	issuer, audience := a.issuer, a.audience
+	a.mu.RUnlock()
+	now := time.Now()
+	iat = now.Unix()
+	exp = now.Add(duration).Unix()
+	clms = jwt.MapClaims{}
 	for k, v := range op.Claims {
 		clms[k] = v
 	}
@@ -94,24 +277,60 @@ func CreateJWT(op *CreateTokenOption) (token *JWTToken, err error) {
 	clms["sub"] = op.Sub
 	clms["iat"] = iat
 	clms["exp"] = exp
+	clms["jti"] = uuid.NewString()
+	if issuer != "" {
+		clms["iss"] = issuer
+	}
+	if len(audience) == 1 {
+		clms["aud"] = audience[0]
+	} else if len(audience) > 1 {
+		clms["aud"] = audience
+	}
+	return clms, iat, exp
+}
+
+// signClaims returns a token of clms signed with the signing key.
+func (a *Authenticator) signClaims(clms jwt.MapClaims) (string, error) {
+	a.mu.RLock()
+	key := a.signingKey
+	a.mu.RUnlock()
+	if key == nil || key.Private == nil {
+		return "", ErrNoSigningKey
+	}
+	t := jwt.NewWithClaims(key.Method, clms)
+	if key.ID != "" {
+		t.Header["kid"] = key.ID
+	}
+	return t.SignedString(key.Private)
+}
+
+func ParseJWT(jwtToken string) (u *TokenClaims, err error) {
+	return defaultAuthenticator.ParseJWT(jwtToken)
+}
 
-	jwtToken, err := t.SignedString(jwtSecret)
+// ParseJWT parses and validates an access token. It returns ErrRefreshTokenAsAccess for refresh
+// tokens, which are parsed by ParseRefreshJWT.
+func (a *Authenticator) ParseJWT(jwtToken string) (u *TokenClaims, err error) {
+	u, err = a.parseJWT(jwtToken)
 	if err != nil {
 		return nil, err
 	}
-	return &JWTToken{
-		AccessToken: jwtToken,
-		Iat:         iat,
-		Exp:         exp,
-	}, nil
+	if typ, _ := u.Claims["typ"].(string); typ == tokenTypeRefresh {
+		return nil, ErrRefreshTokenAsAccess
+	}
+	return u, nil
 }
 
-func ParseJWT(jwtToken string) (u *TokenClaims, err error) {
-	token, err := jwt.Parse(jwtToken, func(t *jwt.Token) (interface{}, error) {
-		if t.Method != jwt.SigningMethodHS256 {
-			return nil, ErrUnexpectedSigningMethod
-		}
-		return jwtSecret, nil
+// parseJWT parses and validates a token of any type.
+func (a *Authenticator) parseJWT(jwtToken string) (u *TokenClaims, err error) {
+	a.mu.RLock()
+	keys, remote := a.verificationKeys, a.remoteKeys
+	issuer, audience, leeway := a.issuer, a.audience, a.leeway
+	a.mu.RUnlock()
+	// The claims are validated below with the leeway.
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(jwtToken, func(t *jwt.Token) (interface{}, error) {
+		return keyFor(t, keys, remote)
 	})
 	if err != nil {
 		return nil, err
@@ -119,18 +338,23 @@ func ParseJWT(jwtToken string) (u *TokenClaims, err error) {
 	if !token.Valid {
 		return nil, ErrTokenInvalid
 	}
-	claims := token.Claims.(jwt.MapClaims)
-	u = &TokenClaims{}
-	u.UID, _ = strconv.ParseInt(claims["uid"].(string), 10, 64)
-	u.Claims = claims
-	u.Exp, _ = claims["exp"].(int64)
-	u.Iat, _ = claims["iat"].(int64)
-
+	u, err = newTokenClaims(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.validate(time.Now(), leeway, issuer, audience); err != nil {
+		return nil, err
+	}
 	return u, nil
 }
 
 func VerifyJWTToken(ctx context.Context) (context.Context, error) {
-	claims, err := verifyJwtToken(ctx)
+	return defaultAuthenticator.VerifyJWTToken(ctx)
+}
+
+// VerifyJWTToken is a grpc_auth.AuthFunc requiring a valid bearer token.
+func (a *Authenticator) VerifyJWTToken(ctx context.Context) (context.Context, error) {
+	claims, err := a.verifyJwtToken(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -138,25 +362,39 @@ func VerifyJWTToken(ctx context.Context) (context.Context, error) {
 }
 
 func VerifyJWTTokenOptional(ctx context.Context) (context.Context, error) {
-	claims, err := verifyJwtToken(ctx)
+	return defaultAuthenticator.VerifyJWTTokenOptional(ctx)
+}
+
+// VerifyJWTTokenOptional is a grpc_auth.AuthFunc setting Anonymous as user of requests without valid token.
+func (a *Authenticator) VerifyJWTTokenOptional(ctx context.Context) (context.Context, error) {
+	claims, err := a.verifyJwtToken(ctx)
 	if err != nil {
 		return context.WithValue(ctx, User, Anonymous), nil
 	}
 	return context.WithValue(ctx, User, claims), nil
 }
 
-func verifyJwtToken(ctx context.Context) (*TokenClaims, error) {
+func (a *Authenticator) verifyJwtToken(ctx context.Context) (*TokenClaims, error) {
 	jwtToken, err := grpc_auth.AuthFromMD(ctx, "bearer")
 	if err != nil {
 		return nil, status.Errorf(codes.Unauthenticated, "token required: %v", err)
 	}
-	claims, err := ParseJWT(jwtToken)
+	claims, err := a.ParseJWT(jwtToken)
 	if err != nil {
 		return nil, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
 	}
+	if err := a.checkRevoked(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid auth token: %v", err)
+		}
+		return nil, status.Errorf(codes.Unavailable, "check token revocation: %v", err)
+	}
 
-	if len(tokenValidators) > 0 {
-		for _, validator := range tokenValidators {
+	a.mu.RLock()
+	optionValidators, tokenValidators := a.optionValidators, a.tokenValidators
+	a.mu.RUnlock()
+	for _, validators := range [][]TokenValidator{optionValidators, tokenValidators} {
+		for _, validator := range validators {
 			err = validator(ctx, claims)
 			if err != nil {
 				return nil, err
@@ -10,20 +10,32 @@ import (
 	"github.com/ahiho/gocandy/utils"
 )
 
-var apiKeys []string
-
 func AddAPIKey(key string) {
+	defaultAuthenticator.AddAPIKey(key)
+}
+
+func (a *Authenticator) AddAPIKey(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if key == "" {
 		return
 	}
-	if !utils.IsInStringArr(apiKeys, key) {
-		apiKeys = append(apiKeys, key)
+	if !utils.IsInStringArr(a.apiKeys, key) {
+		a.apiKeys = append(a.apiKeys[:len(a.apiKeys):len(a.apiKeys)], key)
 	}
 }
 
 func VerifyAPIKey(ctx context.Context) (context.Context, error) {
+	return defaultAuthenticator.VerifyAPIKey(ctx)
+}
+
+// VerifyAPIKey is a grpc_auth.AuthFunc requiring one of the API keys in the x-api-key header.
+func (a *Authenticator) VerifyAPIKey(ctx context.Context) (context.Context, error) {
+	a.mu.RLock()
+	optionAPIKeys, apiKeys := a.optionAPIKeys, a.apiKeys
+	a.mu.RUnlock()
 	apiKey := metautils.ExtractIncoming(ctx).Get("x-api-key")
-	if apiKey == "" || !utils.IsInStringArr(apiKeys, apiKey) {
+	if apiKey == "" || !utils.IsInStringArr(optionAPIKeys, apiKey) && !utils.IsInStringArr(apiKeys, apiKey) {
 		return nil, status.Errorf(codes.Unauthenticated, "invalid api key")
 	}
 	return ctx, nil
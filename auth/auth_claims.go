@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrInvalidClaim          = errors.New("invalid claim")
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenNotValidYet      = errors.New("token not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	ErrInvalidIssuer         = errors.New("invalid issuer")
+	ErrInvalidAudience       = errors.New("invalid audience")
+)
+
+// newTokenClaims returns the TokenClaims of the claims of a token, checking the types of the standard claims.
+func newTokenClaims(claims jwt.MapClaims) (*TokenClaims, error) {
+	u := &TokenClaims{Claims: claims}
+	var err error
+	if u.UID, err = intClaim(claims, "uid"); err != nil {
+		return nil, err
+	}
+	if u.Sub, err = stringClaim(claims, "sub"); err != nil {
+		return nil, err
+	}
+	if u.Iss, err = stringClaim(claims, "iss"); err != nil {
+		return nil, err
+	}
+	if u.Jti, err = stringClaim(claims, "jti"); err != nil {
+		return nil, err
+	}
+	if u.Aud, err = audienceClaim(claims); err != nil {
+		return nil, err
+	}
+	if u.Exp, err = intClaim(claims, "exp"); err != nil {
+		return nil, err
+	}
+	if u.Iat, err = intClaim(claims, "iat"); err != nil {
+		return nil, err
+	}
+	if u.Nbf, err = intClaim(claims, "nbf"); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func stringClaim(claims jwt.MapClaims, name string) (string, error) {
+	switch v := claims[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidClaim, name)
+}
+
+// intClaim returns the integer claim name. It is a JSON number, or a decimal string as the uid claim.
+func intClaim(claims jwt.MapClaims, name string) (int64, error) {
+	switch v := claims[name].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v), nil
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrInvalidClaim, name)
+}
+
+// audienceClaim returns the aud claim, a string or an array of strings.
+func audienceClaim(claims jwt.MapClaims) ([]string, error) {
+	switch v := claims["aud"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		aud := make([]string, 0, len(v))
+		for _, a := range v {
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: aud", ErrInvalidClaim)
+			}
+			aud = append(aud, s)
+		}
+		return aud, nil
+	}
+	return nil, fmt.Errorf("%w: aud", ErrInvalidClaim)
+}
+
+// validate checks the time claims of u allowing leeway for clock skew, and its issuer and audience.
+func (u *TokenClaims) validate(now time.Time, leeway time.Duration, issuer string, audience []string) error {
+	if u.Exp != 0 && now.After(time.Unix(u.Exp, 0).Add(leeway)) {
+		return ErrTokenExpired
+	}
+	if u.Nbf != 0 && now.Before(time.Unix(u.Nbf, 0).Add(-leeway)) {
+		return ErrTokenNotValidYet
+	}
+	if u.Iat != 0 && now.Before(time.Unix(u.Iat, 0).Add(-leeway)) {
+		return ErrTokenUsedBeforeIssued
+	}
+	if issuer != "" && u.Iss != issuer {
+		return ErrInvalidIssuer
+	}
+	if len(audience) > 0 && !u.HasAudience(audience...) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// HasAudience reports whether the token is intended for one of audience.
+func (u *TokenClaims) HasAudience(audience ...string) bool {
+	for _, a := range u.Aud {
+		for _, b := range audience {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClaimsAs decodes the claims of u into T, a struct with json tags, e.g.
+//
+//	type Claims struct {
+//		Role  string `json:"role"`
+//		OrgID string `json:"org_id"`
+//	}
+//	c, err := auth.ClaimsAs[Claims](claims)
+func ClaimsAs[T any](u *TokenClaims) (T, error) {
+	var t T
+	b, err := json.Marshal(u.Claims)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("%w: %v", ErrInvalidClaim, err)
+	}
+	return t, nil
+}
+
+// ParseJWTAs parses jwtToken with a and decodes its claims into T, see ClaimsAs.
+func ParseJWTAs[T any](a *Authenticator, jwtToken string) (*TokenClaims, T, error) {
+	u, err := a.ParseJWT(jwtToken)
+	if err != nil {
+		var t T
+		return nil, t, err
+	}
+	t, err := ClaimsAs[T](u)
+	if err != nil {
+		return nil, t, err
+	}
+	return u, t, nil
+}
+
+// UserFromContext returns the claims set by VerifyJWTToken and VerifyJWTTokenOptional.
+func UserFromContext(ctx context.Context) (*TokenClaims, bool) {
+	switch u := ctx.Value(User).(type) {
+	case *TokenClaims:
+		return u, true
+	case TokenClaims:
+		return &u, true
+	}
+	return nil, false
+}
+
+// UserClaimsAs decodes the claims of the user of ctx into T, see ClaimsAs.
+func UserClaimsAs[T any](ctx context.Context) (T, error) {
+	u, ok := UserFromContext(ctx)
+	if !ok {
+		var t T
+		return t, ErrTokenInvalid
+	}
+	return ClaimsAs[T](u)
+}
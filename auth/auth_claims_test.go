@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestNewTokenClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    *TokenClaims
+		wantErr error
+	}{
+		{
+			name:   "float64",
+			claims: jwt.MapClaims{"uid": float64(42), "exp": float64(1700000000), "iat": float64(1600000000), "nbf": float64(1600000000)},
+			want:   &TokenClaims{UID: 42, Exp: 1700000000, Iat: 1600000000, Nbf: 1600000000},
+		},
+		{
+			name:   "json.Number",
+			claims: jwt.MapClaims{"uid": json.Number("42"), "exp": json.Number("1700000000")},
+			want:   &TokenClaims{UID: 42, Exp: 1700000000},
+		},
+		{
+			name:   "string",
+			claims: jwt.MapClaims{"uid": "42", "sub": "user", "iss": "issuer", "jti": "id", "exp": "1700000000"},
+			want:   &TokenClaims{UID: 42, Sub: "user", Iss: "issuer", Jti: "id", Exp: 1700000000},
+		},
+		{name: "missing uid", claims: jwt.MapClaims{"sub": "user"}, want: &TokenClaims{Sub: "user"}},
+		{name: "empty uid", claims: jwt.MapClaims{"uid": ""}, want: &TokenClaims{}},
+		{name: "fractional float64", claims: jwt.MapClaims{"exp": 1.5}, wantErr: ErrInvalidClaim},
+		{name: "overflowing float64", claims: jwt.MapClaims{"exp": 1e19}, wantErr: ErrInvalidClaim},
+		{name: "fractional json.Number", claims: jwt.MapClaims{"exp": json.Number("1.5")}, wantErr: ErrInvalidClaim},
+		{name: "non decimal uid", claims: jwt.MapClaims{"uid": "abc"}, wantErr: ErrInvalidClaim},
+		{name: "bool uid", claims: jwt.MapClaims{"uid": true}, wantErr: ErrInvalidClaim},
+		{name: "object uid", claims: jwt.MapClaims{"uid": map[string]interface{}{"id": "1"}}, wantErr: ErrInvalidClaim},
+		{name: "non string sub", claims: jwt.MapClaims{"sub": float64(1)}, wantErr: ErrInvalidClaim},
+		{name: "non string iss", claims: jwt.MapClaims{"iss": true}, wantErr: ErrInvalidClaim},
+		{name: "aud string", claims: jwt.MapClaims{"aud": "api"}, want: &TokenClaims{Aud: []string{"api"}}},
+		{name: "aud array", claims: jwt.MapClaims{"aud": []interface{}{"api", "web"}}, want: &TokenClaims{Aud: []string{"api", "web"}}},
+		{name: "aud non string element", claims: jwt.MapClaims{"aud": []interface{}{"api", 1.0}}, wantErr: ErrInvalidClaim},
+		{name: "aud number", claims: jwt.MapClaims{"aud": 1.0}, wantErr: ErrInvalidClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTokenClaims(tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("newTokenClaims() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			tt.want.Claims = tt.claims
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newTokenClaims() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenClaims_validate(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	unix := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	tests := []struct {
+		name     string
+		claims   TokenClaims
+		leeway   time.Duration
+		issuer   string
+		audience []string
+		wantErr  error
+	}{
+		{name: "valid", claims: TokenClaims{Exp: unix(time.Hour), Iat: unix(0), Nbf: unix(0)}},
+		{name: "no time claims", claims: TokenClaims{}},
+		{name: "expired", claims: TokenClaims{Exp: unix(-time.Minute)}, wantErr: ErrTokenExpired},
+		{name: "expired within leeway", claims: TokenClaims{Exp: unix(-time.Minute)}, leeway: 2 * time.Minute},
+		{name: "expired beyond leeway", claims: TokenClaims{Exp: unix(-3 * time.Minute)}, leeway: 2 * time.Minute, wantErr: ErrTokenExpired},
+		{name: "not valid yet", claims: TokenClaims{Nbf: unix(time.Minute)}, wantErr: ErrTokenNotValidYet},
+		{name: "nbf within leeway", claims: TokenClaims{Nbf: unix(time.Minute)}, leeway: 2 * time.Minute},
+		{name: "nbf beyond leeway", claims: TokenClaims{Nbf: unix(3 * time.Minute)}, leeway: 2 * time.Minute, wantErr: ErrTokenNotValidYet},
+		{name: "used before issued", claims: TokenClaims{Iat: unix(time.Minute)}, wantErr: ErrTokenUsedBeforeIssued},
+		{name: "iat within leeway", claims: TokenClaims{Iat: unix(time.Minute)}, leeway: 2 * time.Minute},
+		{name: "iat beyond leeway", claims: TokenClaims{Iat: unix(3 * time.Minute)}, leeway: 2 * time.Minute, wantErr: ErrTokenUsedBeforeIssued},
+		{name: "issuer", claims: TokenClaims{Iss: "issuer"}, issuer: "issuer"},
+		{name: "issuer mismatch", claims: TokenClaims{Iss: "other"}, issuer: "issuer", wantErr: ErrInvalidIssuer},
+		{name: "issuer missing", claims: TokenClaims{}, issuer: "issuer", wantErr: ErrInvalidIssuer},
+		{name: "issuer not checked", claims: TokenClaims{Iss: "other"}},
+		{name: "audience", claims: TokenClaims{Aud: []string{"web", "api"}}, audience: []string{"api"}},
+		{name: "one of audience", claims: TokenClaims{Aud: []string{"web"}}, audience: []string{"api", "web"}},
+		{name: "audience mismatch", claims: TokenClaims{Aud: []string{"web"}}, audience: []string{"api"}, wantErr: ErrInvalidAudience},
+		{name: "audience missing", claims: TokenClaims{}, audience: []string{"api"}, wantErr: ErrInvalidAudience},
+		{name: "audience not checked", claims: TokenClaims{Aud: []string{"web"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.claims.validate(now, tt.leeway, tt.issuer, tt.audience); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_ParseJWTClaims(t *testing.T) {
+	a, err := New(Option{JwtSecret: "secret", Issuer: "issuer", Audience: []string{"api"}, Leeway: time.Minute})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sign := func(claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	now := time.Now()
+
+	token, err := a.CreateJWT(&CreateTokenOption{UID: 7, Sub: "user"})
+	if err != nil {
+		t.Fatalf("CreateJWT() error = %v", err)
+	}
+	u, err := a.ParseJWT(token.AccessToken)
+	if err != nil || u.UID != 7 || u.Iss != "issuer" || !u.HasAudience("api") {
+		t.Errorf("ParseJWT() = %+v, %v, want uid 7 for issuer and api", u, err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr error
+	}{
+		{"numeric uid", jwt.MapClaims{"uid": 7, "iss": "issuer", "aud": "api"}, nil},
+		{"expired within leeway", jwt.MapClaims{"iss": "issuer", "aud": "api", "exp": now.Add(-30 * time.Second).Unix()}, nil},
+		{"expired", jwt.MapClaims{"iss": "issuer", "aud": "api", "exp": now.Add(-2 * time.Minute).Unix()}, ErrTokenExpired},
+		{"issued in the future", jwt.MapClaims{"iss": "issuer", "aud": "api", "iat": now.Add(2 * time.Minute).Unix()}, ErrTokenUsedBeforeIssued},
+		{"other issuer", jwt.MapClaims{"iss": "other", "aud": "api"}, ErrInvalidIssuer},
+		{"other audience", jwt.MapClaims{"iss": "issuer", "aud": []string{"web"}}, ErrInvalidAudience},
+		{"invalid uid", jwt.MapClaims{"uid": []int{7}, "iss": "issuer", "aud": "api"}, ErrInvalidClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.ParseJWT(sign(tt.claims)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseJWT() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,12 +6,16 @@ import (
 
 	firebaseApp "firebase.google.com/go/v4"
 	firebaseAuth "firebase.google.com/go/v4/auth"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var (
-	firAuth *firebaseAuth.Client
-)
+// FirebaseUser is the context key of the *FirebaseClaims set by VerifyFirebaseToken.
+var FirebaseUser = claimKey{
+	Key: "auth.firebase_user",
+}
 
 type FirebaseClaims struct {
 	Token *firebaseAuth.Token
@@ -21,18 +25,32 @@ type FirebaseClaims struct {
 }
 
 func InitFirebase(ctx context.Context, opt option.ClientOption) error {
+	return defaultAuthenticator.InitFirebase(ctx, opt)
+}
+
+func (a *Authenticator) InitFirebase(ctx context.Context, opt option.ClientOption) error {
 	firApp, err := firebaseApp.NewApp(ctx, nil, opt)
 	if err != nil {
 		return err
 	}
-	firAuth, err = firApp.Auth(ctx)
+	firAuth, err := firApp.Auth(ctx)
 	if err != nil {
 		return err
 	}
+	a.mu.Lock()
+	a.firAuth = firAuth
+	a.mu.Unlock()
 	return nil
 }
 
 func ValidateFirebaseToken(token string) (*FirebaseClaims, error) {
+	return defaultAuthenticator.ValidateFirebaseToken(token)
+}
+
+func (a *Authenticator) ValidateFirebaseToken(token string) (*FirebaseClaims, error) {
+	a.mu.RLock()
+	firAuth := a.firAuth
+	a.mu.RUnlock()
 	ftoken, err := firAuth.VerifyIDToken(context.Background(), token)
 	if err != nil {
 		return nil, err
@@ -54,3 +72,26 @@ func ValidateFirebaseToken(token string) (*FirebaseClaims, error) {
 
 	return fc, nil
 }
+
+func VerifyFirebaseToken(ctx context.Context) (context.Context, error) {
+	return defaultAuthenticator.VerifyFirebaseToken(ctx)
+}
+
+// VerifyFirebaseToken is a grpc_auth.AuthFunc requiring a valid Firebase ID token as bearer token.
+func (a *Authenticator) VerifyFirebaseToken(ctx context.Context) (context.Context, error) {
+	token, err := grpc_auth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "token required: %v", err)
+	}
+	a.mu.RLock()
+	initialized := a.firAuth != nil
+	a.mu.RUnlock()
+	if !initialized {
+		return nil, status.Error(codes.Unavailable, "firebase auth not initialized")
+	}
+	claims, err := a.ValidateFirebaseToken(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid firebase token: %v", err)
+	}
+	return context.WithValue(ctx, FirebaseUser, claims), nil
+}
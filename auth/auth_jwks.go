@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultJWKSRefreshInterval = time.Hour
+	// DefaultJWKSMinRefreshInterval limits the refreshes caused by tokens with unknown key IDs.
+	DefaultJWKSMinRefreshInterval = time.Minute
+)
+
+var ErrInvalidJWK = errors.New("invalid jwk")
+
+// JWK is a public key of a JWKS document (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWKS returns the JWKS document of the public keys of keys. HS256 keys are left out.
+func NewJWKS(keys ...*Key) *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, k := range keys {
+		if jwk, ok := newJWK(k); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+func newJWK(k *Key) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = enc.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	default:
+		return jwk, false
+	}
+	return jwk, true
+}
+
+// Key returns the verification key of jwk.
+func (jwk JWK) Key() (*Key, error) {
+	dec := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, jwk.Kid)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := dec(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublicKey(jwk.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, jwk.Crv)
+		}
+		x, err := dec(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, jwk.Kid)
+		}
+		return NewPublicKey(jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, jwk.Kid)
+		}
+		return NewPublicKey(jwk.Kid, ed25519.PublicKey(x))
+	}
+	return nil, fmt.Errorf("%w: key type %s", ErrUnsupportedKey, jwk.Kty)
+}
+
+func JWKSHandler() http.Handler {
+	return defaultAuthenticator.JWKSHandler()
+}
+
+// JWKSHandler serves the JWKS document of the signing and verification keys.
+func (a *Authenticator) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		jwks := NewJWKS(a.verificationKeys...)
+		a.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(jwks)
+	})
+}
+
+// RemoteKeySet is a JWKS document fetched from a URL. It is refreshed after RefreshInterval,
+// and when a token has a key ID which is not in the document, at most once per MinRefreshInterval.
+// The zero values of the fields are the defaults of NewRemoteKeySet.
+type RemoteKeySet struct {
+	URL                string
+	Client             *http.Client
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*Key
+	fetchTime time.Time
+	// fetch is the running fetch of the document, nil if none.
+	fetch *jwksFetch
+}
+
+// jwksFetch is a fetch of the document shared by the callers of Key needing it.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+var defaultJWKSClient = &http.Client{Timeout: 10 * time.Second}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:                url,
+		Client:             defaultJWKSClient,
+		RefreshInterval:    DefaultJWKSRefreshInterval,
+		MinRefreshInterval: DefaultJWKSMinRefreshInterval,
+	}
+}
+
+// Key returns the key with the ID kid. The document is fetched without holding the lock of s, so
+// known keys are returned while it is refreshed, and concurrent callers share one fetch.
+func (s *RemoteKeySet) Key(kid string) (*Key, error) {
+	refresh, minRefresh := s.RefreshInterval, s.MinRefreshInterval
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefreshInterval
+	}
+	if minRefresh <= 0 {
+		minRefresh = DefaultJWKSMinRefreshInterval
+	}
+
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	age := time.Since(s.fetchTime)
+	if (ok && age < refresh) || (!ok && age < minRefresh) {
+		s.mu.Unlock()
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+	f := s.fetch
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		s.fetch = f
+		s.fetchTime = time.Now()
+		s.mu.Unlock()
+		s.refresh(f)
+	} else {
+		s.mu.Unlock()
+		if ok {
+			// The running fetch refreshes the known key.
+			return key, nil
+		}
+		<-f.done
+	}
+
+	if f.err != nil {
+		if ok {
+			// Keep using the known key while the document is unavailable.
+			return key, nil
+		}
+		return nil, f.err
+	}
+	s.mu.Lock()
+	key, ok = s.keys[kid]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh runs f, replacing the keys of s unless it fails.
+func (s *RemoteKeySet) refresh(f *jwksFetch) {
+	keys, err := s.fetchKeys()
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+	}
+	s.fetch = nil
+	s.mu.Unlock()
+	f.err = err
+	close(f.done)
+}
+
+func (s *RemoteKeySet) fetchKeys() (map[string]*Key, error) {
+	client := s.Client
+	if client == nil {
+		client = defaultJWKSClient
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+	jwks := &JWKS{}
+	if err := json.NewDecoder(resp.Body).Decode(jwks); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]*Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,239 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ahiho/gocandy/auth"
+	"github.com/ahiho/gocandy/auth/authtest"
+	"github.com/golang-jwt/jwt"
+)
+
+func newAuthenticator(t *testing.T, op auth.Option) *auth.Authenticator {
+	t.Helper()
+	a, err := auth.New(op)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return a
+}
+
+func publicKey(t *testing.T, k *auth.Key) *auth.Key {
+	t.Helper()
+	pub, err := auth.NewPublicKey(k.ID, k.Public)
+	if err != nil {
+		t.Fatalf("NewPublicKey() error = %v", err)
+	}
+	return pub
+}
+
+func createJWT(t *testing.T, a *auth.Authenticator, sub string) string {
+	t.Helper()
+	token, err := a.CreateJWT(&auth.CreateTokenOption{UID: 1, Sub: sub})
+	if err != nil {
+		t.Fatalf("CreateJWT() error = %v", err)
+	}
+	return token.AccessToken
+}
+
+// parseError returns the error of the key lookup of ParseJWT, which jwt wraps.
+func parseError(err error) error {
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) && ve.Inner != nil {
+		return ve.Inner
+	}
+	return err
+}
+
+func TestAuthenticator_SigningKeys(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			key := authtest.GenerateKey("key-"+alg, alg)
+			issuer := newAuthenticator(t, auth.Option{SigningKey: key})
+			// The verifier knows several keys and picks the one of the kid header.
+			verifier := newAuthenticator(t, auth.Option{VerificationKeys: []*auth.Key{
+				publicKey(t, authtest.GenerateKey("other", alg)),
+				publicKey(t, key),
+			}})
+
+			token := createJWT(t, issuer, "user")
+			for name, a := range map[string]*auth.Authenticator{"issuer": issuer, "verifier": verifier} {
+				u, err := a.ParseJWT(token)
+				if err != nil || u.Sub != "user" || u.UID != 1 {
+					t.Errorf("%s ParseJWT() = %+v, %v, want user", name, u, err)
+				}
+			}
+
+			verifier = newAuthenticator(t, auth.Option{VerificationKeys: []*auth.Key{publicKey(t, authtest.GenerateKey("other", alg))}})
+			if _, err := verifier.ParseJWT(token); !errors.Is(parseError(err), auth.ErrUnknownKey) {
+				t.Errorf("ParseJWT() error = %v, want %v", err, auth.ErrUnknownKey)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_KeyRotation(t *testing.T) {
+	oldKey := authtest.GenerateKey("old", "ES256")
+	newKey := authtest.GenerateKey("new", "ES256")
+	oldToken := createJWT(t, newAuthenticator(t, auth.Option{SigningKey: oldKey}), "old")
+	newToken := createJWT(t, newAuthenticator(t, auth.Option{SigningKey: newKey}), "new")
+
+	verifier := newAuthenticator(t, auth.Option{VerificationKeys: []*auth.Key{publicKey(t, oldKey)}})
+	if _, err := verifier.ParseJWT(newToken); !errors.Is(parseError(err), auth.ErrUnknownKey) {
+		t.Errorf("ParseJWT(new) error = %v, want %v", err, auth.ErrUnknownKey)
+	}
+
+	verifier.AddVerificationKey(publicKey(t, newKey))
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := verifier.ParseJWT(token); err != nil {
+			t.Errorf("ParseJWT() error = %v during the rotation", err)
+		}
+	}
+
+	verifier.RemoveVerificationKey(oldKey.ID)
+	if _, err := verifier.ParseJWT(oldToken); !errors.Is(parseError(err), auth.ErrUnknownKey) {
+		t.Errorf("ParseJWT(old) error = %v, want %v", err, auth.ErrUnknownKey)
+	}
+	if _, err := verifier.ParseJWT(newToken); err != nil {
+		t.Errorf("ParseJWT(new) error = %v", err)
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	first := authtest.GenerateKey("first", "RS256")
+	second := authtest.GenerateKey("second", "EdDSA")
+	server := authtest.NewJWKSServer(first)
+	defer server.Close()
+
+	t.Run("authenticator", func(t *testing.T) {
+		issuer := newAuthenticator(t, auth.Option{SigningKey: first})
+		verifier := newAuthenticator(t, auth.Option{JWKSURL: server.JWKSURL()})
+		if u, err := verifier.ParseJWT(createJWT(t, issuer, "user")); err != nil || u.Sub != "user" {
+			t.Errorf("ParseJWT() = %+v, %v, want user", u, err)
+		}
+	})
+
+	t.Run("refresh on unknown kid", func(t *testing.T) {
+		server.SetKeys(first)
+		keys := auth.NewRemoteKeySet(server.JWKSURL())
+		keys.MinRefreshInterval = time.Nanosecond
+		if _, err := keys.Key(first.ID); err != nil {
+			t.Fatalf("Key(first) error = %v", err)
+		}
+		if _, err := keys.Key(second.ID); !errors.Is(err, auth.ErrUnknownKey) {
+			t.Fatalf("Key(second) error = %v, want %v", err, auth.ErrUnknownKey)
+		}
+
+		server.SetKeys(first, second)
+		time.Sleep(time.Millisecond)
+		key, err := keys.Key(second.ID)
+		if err != nil || key.Method.Alg() != "EdDSA" {
+			t.Errorf("Key(second) = %+v, %v, want EdDSA key", key, err)
+		}
+	})
+
+	t.Run("refresh limited", func(t *testing.T) {
+		server.SetKeys(first)
+		keys := auth.NewRemoteKeySet(server.JWKSURL())
+		if _, err := keys.Key(first.ID); err != nil {
+			t.Fatalf("Key(first) error = %v", err)
+		}
+		server.SetKeys(first, second)
+		if _, err := keys.Key(second.ID); !errors.Is(err, auth.ErrUnknownKey) {
+			t.Errorf("Key(second) error = %v, want %v before MinRefreshInterval", err, auth.ErrUnknownKey)
+		}
+	})
+
+	t.Run("struct literal", func(t *testing.T) {
+		server.SetKeys(first)
+		keys := &auth.RemoteKeySet{URL: server.JWKSURL()}
+		if _, err := keys.Key(first.ID); err != nil {
+			t.Fatalf("Key(first) error = %v", err)
+		}
+		server.SetKeys(second)
+		if _, err := keys.Key(first.ID); err != nil {
+			t.Errorf("Key(first) error = %v, want the cached key before RefreshInterval", err)
+		}
+		if _, err := keys.Key(second.ID); !errors.Is(err, auth.ErrUnknownKey) {
+			t.Errorf("Key(second) error = %v, want %v before MinRefreshInterval", err, auth.ErrUnknownKey)
+		}
+	})
+}
+
+func TestRemoteKeySet_ConcurrentFetch(t *testing.T) {
+	first := authtest.GenerateKey("first", "RS256")
+	second := authtest.GenerateKey("second", "EdDSA")
+	var (
+		mu      sync.Mutex
+		served  = []*auth.Key{first}
+		fetches int32
+		block   = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-block
+		}
+		mu.Lock()
+		jwks := auth.NewJWKS(served...)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	keys := auth.NewRemoteKeySet(server.URL)
+	keys.MinRefreshInterval = time.Nanosecond
+	if _, err := keys.Key(first.ID); err != nil {
+		t.Fatalf("Key(first) error = %v", err)
+	}
+	mu.Lock()
+	served = []*auth.Key{first, second}
+	mu.Unlock()
+	time.Sleep(time.Millisecond)
+
+	// The first caller runs the blocked refresh, the others wait for it instead of fetching again.
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(second.ID)
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := keys.Key(first.ID)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Key(first) error = %v during a refresh", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Key(first) blocked by the refresh")
+	}
+
+	close(block)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key(second) error = %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
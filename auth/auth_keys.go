@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key")
+	ErrInvalidPEM     = errors.New("invalid pem")
+	ErrUnknownKey     = errors.New("unknown key")
+	ErrNoSigningKey   = errors.New("no signing key")
+)
+
+// Key signs or verifies tokens.
+type Key struct {
+	// ID is set as kid header of the tokens signed with the key. Keys without ID verify tokens without kid.
+	ID     string
+	Method jwt.SigningMethod
+	// Private signs tokens, it is nil for keys which only verify tokens.
+	Private interface{}
+	// Public verifies tokens. It is the secret for HS256 keys.
+	Public interface{}
+}
+
+// NewHMACKey returns a HS256 key.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:      id,
+		Method:  jwt.SigningMethodHS256,
+		Private: secret,
+		Public:  secret,
+	}
+}
+
+// NewKey returns a key signing with an *rsa.PrivateKey (RS256), *ecdsa.PrivateKey (ES256, ES384 or
+// ES512 depending on the curve) or ed25519.PrivateKey (EdDSA).
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	method, err := signingMethod(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:      id,
+		Method:  method,
+		Private: private,
+		Public:  private.Public(),
+	}, nil
+}
+
+// NewPublicKey returns a key verifying tokens with an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func NewPublicKey(id string, public crypto.PublicKey) (*Key, error) {
+	method, err := signingMethod(public)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:     id,
+		Method: method,
+		Public: public,
+	}, nil
+}
+
+// ParsePrivateKeyPEM parses a PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) private key.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPEM, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return NewKey(id, signer)
+}
+
+// ParsePublicKeyPEM parses a PKIX or PKCS #1 (RSA) public key, or the public key of a certificate.
+func ParsePublicKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+	var public interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			public = cert.PublicKey
+		}
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPEM, err)
+	}
+	return NewPublicKey(id, public)
+}
+
+func signingMethod(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+}
+
+// keyFor returns the verification key of t from keys, by kid header and algorithm. Tokens
+// without kid match the first key without ID of their algorithm, e.g. JwtSecret next to an
+// RSA SigningKey without ID.
+func keyFor(t *jwt.Token, keys []*Key, remote *RemoteKeySet) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	var key *Key
+	for _, k := range keys {
+		if k.ID == kid && (kid != "" || k.Method.Alg() == t.Method.Alg()) {
+			key = k
+			break
+		}
+	}
+	if key == nil && remote != nil && kid != "" {
+		var err error
+		if key, err = remote.Key(kid); err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key.Public, nil
+}
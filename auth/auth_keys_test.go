@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func generateKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey}
+}
+
+func TestKeyFor(t *testing.T) {
+	signers := generateKeys(t)
+	rsaKey, _ := NewKey("rsa", signers["RS256"])
+	ecKey, _ := NewKey("ec", signers["ES256"])
+	secret := NewHMACKey("", []byte("secret"))
+	keys := []*Key{rsaKey, ecKey, secret}
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		kid     string
+		want    *Key
+		wantErr error
+	}{
+		{"by kid", jwt.SigningMethodES256, "ec", ecKey, nil},
+		{"without kid", jwt.SigningMethodHS256, "", secret, nil},
+		{"unknown kid", jwt.SigningMethodRS256, "other", nil, ErrUnknownKey},
+		// An HS256 token signed with the public RSA key as secret must not verify.
+		{"algorithm confusion", jwt.SigningMethodHS256, "rsa", nil, ErrUnexpectedSigningMethod},
+		{"other algorithm", jwt.SigningMethodES256, "rsa", nil, ErrUnexpectedSigningMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.New(tt.method)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			got, err := keyFor(token, keys, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("keyFor() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want.Public) {
+				t.Errorf("keyFor() = %v, want %v", got, tt.want.Public)
+			}
+		})
+	}
+
+	t.Run("mixed keys without ID", func(t *testing.T) {
+		rsaKey, _ := NewKey("", signers["RS256"])
+		a, err := New(Option{SigningKey: rsaKey, JwtSecret: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS256, jwt.SigningMethodHS256} {
+			token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "user"})
+			var signed string
+			if method == jwt.SigningMethodHS256 {
+				signed, err = token.SignedString([]byte("secret"))
+			} else {
+				signed, err = token.SignedString(signers["RS256"])
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u, err := a.ParseJWT(signed); err != nil || u.Claims["sub"] != "user" {
+				t.Errorf("ParseJWT(%s) = %+v, %v, want user", method.Alg(), u, err)
+			}
+		}
+	})
+
+	t.Run("confused token", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(rsaKey.Public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "attacker"})
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := New(Option{SigningKey: rsaKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u, err := a.ParseJWT(signed); err == nil {
+			t.Errorf("ParseJWT() = %+v, want error", u)
+		}
+	})
+}
+
+func TestParsePEM(t *testing.T) {
+	for alg, signer := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			pkcs8, err := x509.MarshalPKCS8PrivateKey(signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spki, err := x509.MarshalPKIXPublicKey(signer.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			private := []*pem.Block{{Type: "PRIVATE KEY", Bytes: pkcs8}}
+			public := []*pem.Block{{Type: "PUBLIC KEY", Bytes: spki}, {Type: "CERTIFICATE", Bytes: certificate(t, signer)}}
+			switch k := signer.(type) {
+			case *rsa.PrivateKey:
+				private = append(private, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)})
+				public = append(public, &pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&k.PublicKey)})
+			case *ecdsa.PrivateKey:
+				sec1, err := x509.MarshalECPrivateKey(k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				private = append(private, &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})
+			}
+
+			for _, block := range private {
+				key, err := ParsePrivateKeyPEM("id", pem.EncodeToMemory(block))
+				if err != nil {
+					t.Fatalf("ParsePrivateKeyPEM(%s) error = %v", block.Type, err)
+				}
+				if key.ID != "id" || key.Method.Alg() != alg || key.Private == nil {
+					t.Errorf("ParsePrivateKeyPEM(%s) = %+v, want %s key", block.Type, key, alg)
+				}
+			}
+			for _, block := range public {
+				key, err := ParsePublicKeyPEM("id", pem.EncodeToMemory(block))
+				if err != nil {
+					t.Fatalf("ParsePublicKeyPEM(%s) error = %v", block.Type, err)
+				}
+				if key.ID != "id" || key.Method.Alg() != alg || key.Private != nil {
+					t.Errorf("ParsePublicKeyPEM(%s) = %+v, want %s key", block.Type, key, alg)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ParsePrivateKeyPEM("id", []byte("not a key")); !errors.Is(err, ErrInvalidPEM) {
+			t.Errorf("ParsePrivateKeyPEM() error = %v, want %v", err, ErrInvalidPEM)
+		}
+		block := &pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")}
+		if _, err := ParsePublicKeyPEM("id", pem.EncodeToMemory(block)); !errors.Is(err, ErrInvalidPEM) {
+			t.Errorf("ParsePublicKeyPEM() error = %v, want %v", err, ErrInvalidPEM)
+		}
+	})
+}
+
+func certificate(t *testing.T, signer crypto.Signer) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Policy is the authentication required to call a method.
+type Policy string
+
+const (
+	// PolicyRequired requires a valid JWT, see VerifyJWTToken.
+	PolicyRequired Policy = "required"
+	// PolicyOptional accepts requests without valid JWT as Anonymous, see VerifyJWTTokenOptional.
+	PolicyOptional Policy = "optional"
+	// PolicyAPIKey requires an API key, see VerifyAPIKey.
+	PolicyAPIKey Policy = "api_key"
+	// PolicyFirebase requires a Firebase ID token, see VerifyFirebaseToken.
+	PolicyFirebase Policy = "firebase"
+	// PolicyNone lets all requests through.
+	PolicyNone Policy = "none"
+	// PolicyDeny rejects all requests.
+	PolicyDeny Policy = "deny"
+)
+
+var (
+	ErrUnknownPolicy = errors.New("unknown auth policy")
+	ErrInvalidOption = errors.New("invalid auth policy option")
+)
+
+func (p Policy) valid() bool {
+	switch p {
+	case PolicyRequired, PolicyOptional, PolicyAPIKey, PolicyFirebase, PolicyNone, PolicyDeny:
+		return true
+	}
+	return false
+}
+
+// PolicyPattern applies Policy to the methods matching Pattern, a path.Match pattern of full method
+// names, e.g. "/pkg.UserService/*" or "/grpc.health.v1.Health/*".
+type PolicyPattern struct {
+	Pattern string
+	Policy  Policy
+}
+
+type PolicyOption struct {
+	// Authenticator verifies the credentials, the default Authenticator if nil.
+	Authenticator *Authenticator
+	// Methods are the policies by full method name, e.g. "/pkg.UserService/GetUser".
+	Methods map[string]Policy
+	// Patterns are the policies of the methods not in Methods, the first matching pattern applies.
+	Patterns []PolicyPattern
+	// MethodOption is a string or enum extension of google.protobuf.MethodOptions setting the policy
+	// in the proto files. It takes precedence over Patterns. Enum values match by their name suffix,
+	// e.g. AUTH_POLICY_API_KEY is PolicyAPIKey.
+	MethodOption protoreflect.ExtensionType
+	// Default is the policy of the methods without policy, PolicyDeny if empty. Leaving it empty
+	// ensures that new methods are not exposed before their policy is chosen.
+	Default Policy
+}
+
+// PolicyEnforcer authenticates the requests to each method according to its policy.
+type PolicyEnforcer struct {
+	op       PolicyOption
+	policies sync.Map
+}
+
+// NewPolicyEnforcer returns a PolicyEnforcer of op. It returns an error for unknown policies and
+// malformed patterns, so that a typo does not disable the authentication of methods.
+func NewPolicyEnforcer(op PolicyOption) (*PolicyEnforcer, error) {
+	if op.Authenticator == nil {
+		op.Authenticator = defaultAuthenticator
+	}
+	if op.Default == "" {
+		op.Default = PolicyDeny
+	}
+	if !op.Default.valid() {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, op.Default)
+	}
+	for method, policy := range op.Methods {
+		if !policy.valid() {
+			return nil, fmt.Errorf("%w: %q for %s", ErrUnknownPolicy, policy, method)
+		}
+	}
+	for _, p := range op.Patterns {
+		if !p.Policy.valid() {
+			return nil, fmt.Errorf("%w: %q for %s", ErrUnknownPolicy, p.Policy, p.Pattern)
+		}
+		if _, err := path.Match(p.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, p.Pattern)
+		}
+	}
+	if op.MethodOption != nil {
+		xd := op.MethodOption.TypeDescriptor()
+		if xd.ContainingMessage().FullName() != "google.protobuf.MethodOptions" ||
+			(xd.Kind() != protoreflect.StringKind && xd.Kind() != protoreflect.EnumKind) || xd.IsList() {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOption, xd.FullName())
+		}
+	}
+	return &PolicyEnforcer{op: op}, nil
+}
+
+// PolicyFor returns the policy of fullMethod: from Methods, MethodOption, Patterns, or else Default.
+func (e *PolicyEnforcer) PolicyFor(fullMethod string) Policy {
+	if p, ok := e.policies.Load(fullMethod); ok {
+		return p.(Policy)
+	}
+	p := e.policyFor(fullMethod)
+	e.policies.Store(fullMethod, p)
+	return p
+}
+
+func (e *PolicyEnforcer) policyFor(fullMethod string) Policy {
+	if p, ok := e.op.Methods[fullMethod]; ok {
+		return p
+	}
+	if p, ok := e.optionPolicy(fullMethod); ok {
+		return p
+	}
+	for _, p := range e.op.Patterns {
+		if ok, _ := path.Match(p.Pattern, fullMethod); ok {
+			return p.Policy
+		}
+	}
+	return e.op.Default
+}
+
+// optionPolicy returns the policy set by MethodOption on the method descriptor of fullMethod.
+// An invalid value is PolicyDeny.
+func (e *PolicyEnforcer) optionPolicy(fullMethod string) (Policy, bool) {
+	if e.op.MethodOption == nil {
+		return "", false
+	}
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1))
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return "", false
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return "", false
+	}
+	opts, ok := md.Options().(*descriptorpb.MethodOptions)
+	if !ok || opts == nil || !proto.HasExtension(opts, e.op.MethodOption) {
+		return "", false
+	}
+
+	var p Policy
+	switch v := proto.GetExtension(opts, e.op.MethodOption).(type) {
+	case string:
+		p = Policy(strings.ToLower(v))
+	case protoreflect.Enum:
+		if ev := v.Descriptor().Values().ByNumber(v.Number()); ev != nil {
+			p = enumPolicy(string(ev.Name()))
+		}
+	}
+	if !p.valid() {
+		return PolicyDeny, true
+	}
+	return p, true
+}
+
+// enumPolicy returns the policy of an enum value name such as AUTH_POLICY_API_KEY.
+func enumPolicy(name string) Policy {
+	name = strings.ToLower(name)
+	for _, p := range []Policy{PolicyRequired, PolicyOptional, PolicyAPIKey, PolicyFirebase, PolicyNone, PolicyDeny} {
+		if name == string(p) || strings.HasSuffix(name, "_"+string(p)) {
+			return p
+		}
+	}
+	return ""
+}
+
+// Authorize authenticates a request to fullMethod according to its policy.
+func (e *PolicyEnforcer) Authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	a := e.op.Authenticator
+	switch e.PolicyFor(fullMethod) {
+	case PolicyRequired:
+		return a.VerifyJWTToken(ctx)
+	case PolicyOptional:
+		return a.VerifyJWTTokenOptional(ctx)
+	case PolicyAPIKey:
+		return a.VerifyAPIKey(ctx)
+	case PolicyFirebase:
+		return a.VerifyFirebaseToken(ctx)
+	case PolicyNone:
+		return ctx, nil
+	}
+	return nil, status.Errorf(codes.PermissionDenied, "method %s is not allowed", fullMethod)
+}
+
+// AuthFunc is a grpc_auth.AuthFunc applying the policy of the method of the request.
+func (e *PolicyEnforcer) AuthFunc(ctx context.Context) (context.Context, error) {
+	method, ok := grpc.Method(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "method of the request unknown")
+	}
+	return e.Authorize(ctx, method)
+}
+
+func (e *PolicyEnforcer) UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	newCtx, err := e.Authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(newCtx, req)
+}
+
+func (e *PolicyEnforcer) StreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	newCtx, err := e.Authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &policyServerStream{ServerStream: ss, ctx: newCtx})
+}
+
+type policyServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *policyServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const testService = "/gocandy.auth.test.TestService/"
+
+// testOptions are the extensions of the test proto files, registered once in GlobalFiles.
+var testOptions struct {
+	once     sync.Once
+	policy   protoreflect.ExtensionType // string
+	enum     protoreflect.ExtensionType // enum AuthPolicy
+	number   protoreflect.ExtensionType // int32
+	fieldOpt protoreflect.ExtensionType // string of FieldOptions
+}
+
+// enumExtension returns the values of an enum extension as protoreflect.Enum like generated code,
+// where dynamicpb returns protoreflect.EnumNumber.
+type enumExtension struct {
+	protoreflect.ExtensionType
+}
+
+func (xt enumExtension) InterfaceOf(v protoreflect.Value) interface{} {
+	return dynamicpb.NewEnumType(xt.TypeDescriptor().Enum()).New(v.Enum())
+}
+
+func registerTestOptions(t *testing.T) {
+	t.Helper()
+	testOptions.once.Do(func() {
+		optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		extension := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, extendee string) *descriptorpb.FieldDescriptorProto {
+			x := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(name),
+				Number:   proto.Int32(number),
+				Label:    optional,
+				Type:     typ.Enum(),
+				Extendee: proto.String(extendee),
+			}
+			if typ == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
+				x.TypeName = proto.String(".gocandy.auth.test.AuthPolicy")
+			}
+			return x
+		}
+		enumValue := func(name string, number int32) *descriptorpb.EnumValueDescriptorProto {
+			return &descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(number)}
+		}
+		options := mustRegisterFile(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("gocandy/auth/test/options.proto"),
+			Package:    proto.String("gocandy.auth.test"),
+			Dependency: []string{"google/protobuf/descriptor.proto"},
+			Syntax:     proto.String("proto3"),
+			EnumType: []*descriptorpb.EnumDescriptorProto{{
+				Name: proto.String("AuthPolicy"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					enumValue("AUTH_POLICY_UNSPECIFIED", 0),
+					enumValue("AUTH_POLICY_API_KEY", 1),
+					enumValue("AUTH_POLICY_NONE", 2),
+				},
+			}},
+			Extension: []*descriptorpb.FieldDescriptorProto{
+				extension("policy", 50001, descriptorpb.FieldDescriptorProto_TYPE_STRING, ".google.protobuf.MethodOptions"),
+				extension("policy_enum", 50002, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".google.protobuf.MethodOptions"),
+				extension("policy_number", 50003, descriptorpb.FieldDescriptorProto_TYPE_INT32, ".google.protobuf.MethodOptions"),
+				extension("field_policy", 50004, descriptorpb.FieldDescriptorProto_TYPE_STRING, ".google.protobuf.FieldOptions"),
+			},
+		})
+		xds := options.Extensions()
+		testOptions.policy = dynamicpb.NewExtensionType(xds.ByName("policy"))
+		testOptions.enum = enumExtension{dynamicpb.NewExtensionType(xds.ByName("policy_enum"))}
+		testOptions.number = dynamicpb.NewExtensionType(xds.ByName("policy_number"))
+		testOptions.fieldOpt = dynamicpb.NewExtensionType(xds.ByName("field_policy"))
+
+		method := func(name string, xt protoreflect.ExtensionType, v interface{}) *descriptorpb.MethodDescriptorProto {
+			md := &descriptorpb.MethodDescriptorProto{
+				Name:       proto.String(name),
+				InputType:  proto.String(".gocandy.auth.test.Empty"),
+				OutputType: proto.String(".gocandy.auth.test.Empty"),
+			}
+			if xt != nil {
+				md.Options = &descriptorpb.MethodOptions{}
+				proto.SetExtension(md.Options, xt, v)
+			}
+			return md
+		}
+		mustRegisterFile(&descriptorpb.FileDescriptorProto{
+			Name:        proto.String("gocandy/auth/test/service.proto"),
+			Package:     proto.String("gocandy.auth.test"),
+			Dependency:  []string{"gocandy/auth/test/options.proto"},
+			Syntax:      proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("TestService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("Plain", nil, nil),
+					method("APIKey", testOptions.policy, "API_KEY"),
+					method("Invalid", testOptions.policy, "admin"),
+					method("EnumNone", testOptions.enum, protoreflect.EnumNumber(2)),
+					method("EnumUnspecified", testOptions.enum, protoreflect.EnumNumber(0)),
+				},
+			}},
+		})
+	})
+	if testOptions.policy == nil {
+		t.Fatal("test proto files not registered")
+	}
+}
+
+func mustRegisterFile(fdp *descriptorpb.FileDescriptorProto) protoreflect.FileDescriptor {
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		panic(err)
+	}
+	return fd
+}
+
+func newPolicyEnforcer(t *testing.T, op PolicyOption) *PolicyEnforcer {
+	t.Helper()
+	e, err := NewPolicyEnforcer(op)
+	if err != nil {
+		t.Fatalf("NewPolicyEnforcer() error = %v", err)
+	}
+	return e
+}
+
+func TestPolicyEnforcer_PolicyFor(t *testing.T) {
+	registerTestOptions(t)
+	methods := map[string]Policy{testService + "APIKey": PolicyRequired, "/pkg.Service/Method": PolicyOptional}
+	patterns := []PolicyPattern{
+		{Pattern: testService + "*", Policy: PolicyFirebase},
+		{Pattern: "/pkg.*/*", Policy: PolicyNone},
+		{Pattern: "/pkg.Service/*", Policy: PolicyRequired},
+	}
+
+	tests := []struct {
+		name   string
+		op     PolicyOption
+		method string
+		want   Policy
+	}{
+		{"methods over option", PolicyOption{Methods: methods, MethodOption: testOptions.policy}, testService + "APIKey", PolicyRequired},
+		{"methods over patterns", PolicyOption{Methods: methods, Patterns: patterns}, "/pkg.Service/Method", PolicyOptional},
+		{"option over patterns", PolicyOption{MethodOption: testOptions.policy, Patterns: patterns}, testService + "APIKey", PolicyAPIKey},
+		{"invalid option", PolicyOption{MethodOption: testOptions.policy, Patterns: patterns}, testService + "Invalid", PolicyDeny},
+		{"enum option", PolicyOption{MethodOption: testOptions.enum, Patterns: patterns}, testService + "EnumNone", PolicyNone},
+		{"unspecified enum option", PolicyOption{MethodOption: testOptions.enum, Patterns: patterns}, testService + "EnumUnspecified", PolicyDeny},
+		{"patterns without option", PolicyOption{MethodOption: testOptions.policy, Patterns: patterns}, testService + "Plain", PolicyFirebase},
+		{"other option", PolicyOption{MethodOption: testOptions.enum, Patterns: patterns}, testService + "APIKey", PolicyFirebase},
+		{"first pattern", PolicyOption{Patterns: patterns}, "/pkg.Service/Other", PolicyNone},
+		{"default", PolicyOption{Patterns: patterns, Default: PolicyOptional}, "/other.Service/Method", PolicyOptional},
+		{"default deny", PolicyOption{Methods: methods, Patterns: patterns}, "/other.Service/Method", PolicyDeny},
+		{"unknown method with option", PolicyOption{MethodOption: testOptions.policy}, "/other.Service/Method", PolicyDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newPolicyEnforcer(t, tt.op)
+			for i := 0; i < 2; i++ {
+				if got := e.PolicyFor(tt.method); got != tt.want {
+					t.Errorf("PolicyFor(%s) = %q, want %q", tt.method, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPolicyEnforcer(t *testing.T) {
+	registerTestOptions(t)
+	tests := []struct {
+		name    string
+		op      PolicyOption
+		wantErr error
+	}{
+		{"valid", PolicyOption{Methods: map[string]Policy{"/pkg.Service/Method": PolicyNone}, MethodOption: testOptions.enum}, nil},
+		{"unknown default", PolicyOption{Default: "public"}, ErrUnknownPolicy},
+		{"unknown method policy", PolicyOption{Methods: map[string]Policy{"/pkg.Service/Method": "Required"}}, ErrUnknownPolicy},
+		{"unknown pattern policy", PolicyOption{Patterns: []PolicyPattern{{Pattern: "/pkg.Service/*", Policy: "public"}}}, ErrUnknownPolicy},
+		{"malformed pattern", PolicyOption{Patterns: []PolicyPattern{{Pattern: "/pkg.Service/[", Policy: PolicyNone}}}, path.ErrBadPattern},
+		{"int32 option", PolicyOption{MethodOption: testOptions.number}, ErrInvalidOption},
+		{"field option", PolicyOption{MethodOption: testOptions.fieldOpt}, ErrInvalidOption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPolicyEnforcer(tt.op); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewPolicyEnforcer() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnumPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		want Policy
+	}{
+		{"AUTH_POLICY_REQUIRED", PolicyRequired},
+		{"AUTH_POLICY_OPTIONAL", PolicyOptional},
+		{"AUTH_POLICY_API_KEY", PolicyAPIKey},
+		{"AUTH_POLICY_FIREBASE", PolicyFirebase},
+		{"AUTH_POLICY_NONE", PolicyNone},
+		{"AUTH_POLICY_DENY", PolicyDeny},
+		{"API_KEY", PolicyAPIKey},
+		{"none", PolicyNone},
+		{"AUTH_POLICY_UNSPECIFIED", ""},
+		{"AUTH_POLICY_KEY", ""},
+		{"AUTH_POLICYNONE", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := enumPolicy(tt.name); got != tt.want {
+				t.Errorf("enumPolicy(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyEnforcer_Authorize(t *testing.T) {
+	a, err := New(Option{JwtSecret: "secret", APIKeys: []string{"key"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	token, err := a.CreateJWT(&CreateTokenOption{UID: 1, Sub: "user"})
+	if err != nil {
+		t.Fatalf("CreateJWT() error = %v", err)
+	}
+	e := newPolicyEnforcer(t, PolicyOption{Authenticator: a, Methods: map[string]Policy{
+		"/pkg.Service/Required": PolicyRequired,
+		"/pkg.Service/Optional": PolicyOptional,
+		"/pkg.Service/APIKey":   PolicyAPIKey,
+		"/pkg.Service/None":     PolicyNone,
+		"/pkg.Service/Deny":     PolicyDeny,
+	}})
+
+	tests := []struct {
+		method string
+		ctx    context.Context
+		want   codes.Code
+	}{
+		{"/pkg.Service/Required", bearer(token.AccessToken), codes.OK},
+		{"/pkg.Service/Required", context.Background(), codes.Unauthenticated},
+		{"/pkg.Service/Optional", context.Background(), codes.OK},
+		{"/pkg.Service/APIKey", bearer(token.AccessToken), codes.Unauthenticated},
+		{"/pkg.Service/None", context.Background(), codes.OK},
+		{"/pkg.Service/Deny", bearer(token.AccessToken), codes.PermissionDenied},
+		{"/pkg.Service/Unknown", bearer(token.AccessToken), codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		if _, err := e.Authorize(tt.ctx, tt.method); status.Code(err) != tt.want {
+			t.Errorf("Authorize(%s) error = %v, want code %v", tt.method, err, tt.want)
+		}
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestPolicyEnforcer_Interceptors(t *testing.T) {
+	a, err := New(Option{JwtSecret: "secret"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	token, err := a.CreateJWT(&CreateTokenOption{UID: 1, Sub: "user"})
+	if err != nil {
+		t.Fatalf("CreateJWT() error = %v", err)
+	}
+	e := newPolicyEnforcer(t, PolicyOption{Authenticator: a, Methods: map[string]Policy{"/pkg.Service/Method": PolicyRequired}})
+	checkUser := func(ctx context.Context) error {
+		if u, ok := UserFromContext(ctx); !ok || u.Sub != "user" {
+			t.Errorf("UserFromContext() = %+v, %v, want user", u, ok)
+		}
+		return nil
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) { return req, checkUser(ctx) }
+		info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+		if _, err := e.UnaryServerInterceptor(bearer(token.AccessToken), "req", info, handler); err != nil {
+			t.Errorf("UnaryServerInterceptor() error = %v", err)
+		}
+		info.FullMethod = "/pkg.Service/Other"
+		denied := func(context.Context, interface{}) (interface{}, error) {
+			t.Error("handler called for a denied method")
+			return nil, nil
+		}
+		if _, err := e.UnaryServerInterceptor(bearer(token.AccessToken), "req", info, denied); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("UnaryServerInterceptor() error = %v, want %v", err, codes.PermissionDenied)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		handler := func(srv interface{}, ss grpc.ServerStream) error { return checkUser(ss.Context()) }
+		info := &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Method"}
+		ss := &testServerStream{ctx: bearer(token.AccessToken)}
+		if err := e.StreamServerInterceptor(nil, ss, info, handler); err != nil {
+			t.Errorf("StreamServerInterceptor() error = %v", err)
+		}
+		ss.ctx = context.Background()
+		denied := func(interface{}, grpc.ServerStream) error {
+			t.Error("handler called without credentials")
+			return nil
+		}
+		if err := e.StreamServerInterceptor(nil, ss, info, denied); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("StreamServerInterceptor() error = %v, want %v", err, codes.Unauthenticated)
+		}
+	})
+}
@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultRefreshDuration = time.Hour * 24 * 30
+	DefaultAccessDuration  = time.Minute * 15
+
+	tokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrTokenRevoked         = errors.New("token revoked")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrNotRefreshToken      = errors.New("not a refresh token")
+	ErrRefreshTokenAsAccess = errors.New("refresh token used as access token")
+	ErrNoRefreshTokenStore  = errors.New("no refresh token store")
+	ErrNoRevocationStore    = errors.New("no revocation store")
+	ErrNoTokenID            = errors.New("token has no id")
+)
+
+// RevocationStore stores revoked tokens. VerifyJWTToken rejects the tokens revoked by ID, and the
+// tokens of a subject or refresh token family issued until its revocation time. The iat claim has a
+// granularity of one second, so the tokens issued in the second of the revocation are revoked too.
+type RevocationStore interface {
+	// Revoke revokes the token with the ID jti. It can be forgotten after exp, when the token expires,
+	// and never if exp is the zero time, for tokens without exp claim.
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeSubject revokes the tokens of sub issued until t.
+	RevokeSubject(ctx context.Context, sub string, t time.Time) error
+	// SubjectRevokeTime returns the time until which the tokens of sub are revoked, or the zero time.
+	SubjectRevokeTime(ctx context.Context, sub string) (time.Time, error)
+	// RevokeFamily revokes the access and refresh tokens of the refresh token family issued until t.
+	RevokeFamily(ctx context.Context, family string, t time.Time) error
+	// FamilyRevokeTime returns the time until which the tokens of family are revoked, or the zero time.
+	FamilyRevokeTime(ctx context.Context, family string) (time.Time, error)
+}
+
+// RefreshTokenStore stores the current token of each refresh token family. A family is the chain of
+// refresh tokens rotated from one login, only its latest token can be used.
+type RefreshTokenStore interface {
+	// SaveRefreshToken starts family with the token jti.
+	SaveRefreshToken(ctx context.Context, family string, jti string, exp time.Time) error
+	// RotateRefreshToken replaces the current token old of family with new. It returns
+	// ErrRefreshTokenReused if old is not the current token of family, and then deletes the family.
+	RotateRefreshToken(ctx context.Context, family string, old string, new string, exp time.Time) error
+	DeleteRefreshTokens(ctx context.Context, family string) error
+}
+
+// stores returns the stores of a.
+func (a *Authenticator) stores() (RevocationStore, RefreshTokenStore) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.revocationStore, a.refreshTokenStore
+}
+
+func CreateJWTWithRefresh(ctx context.Context, op *CreateTokenOption) (*JWTToken, error) {
+	return defaultAuthenticator.CreateJWTWithRefresh(ctx, op)
+}
+
+// CreateJWTWithRefresh creates an access token and the refresh token of a new refresh token family.
+// The access token expires after op.ExpireIn, or else after the AccessDuration of a.
+func (a *Authenticator) CreateJWTWithRefresh(ctx context.Context, op *CreateTokenOption) (*JWTToken, error) {
+	_, refreshTokenStore := a.stores()
+	if refreshTokenStore == nil {
+		return nil, ErrNoRefreshTokenStore
+	}
+	family := uuid.NewString()
+	token, jti, err := a.newTokenPair(op, family)
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshTokenStore.SaveRefreshToken(ctx, family, jti, time.Unix(token.RefreshExp, 0)); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func RefreshJWT(ctx context.Context, refreshToken string) (*JWTToken, error) {
+	return defaultAuthenticator.RefreshJWT(ctx, refreshToken)
+}
+
+// RefreshJWT creates a new access token and rotates the refresh token of its family. Reusing a rotated
+// refresh token revokes its family, as the token has been stolen either by the caller or from it.
+// The access tokens of the family are revoked as well if a has a RevocationStore.
+func (a *Authenticator) RefreshJWT(ctx context.Context, refreshToken string) (*JWTToken, error) {
+	revocationStore, refreshTokenStore := a.stores()
+	if refreshTokenStore == nil {
+		return nil, ErrNoRefreshTokenStore
+	}
+	claims, err := a.ParseRefreshJWT(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	family, _ := claims.Claims["fam"].(string)
+	if err := a.checkRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	op := &CreateTokenOption{UID: claims.UID, Sub: claims.Sub, Claims: map[string]string{}}
+	for k, v := range claims.Claims {
+		if s, ok := v.(string); ok && !isReservedClaim(k) {
+			op.Claims[k] = s
+		}
+	}
+	token, jti, err := a.newTokenPair(op, family)
+	if err != nil {
+		return nil, err
+	}
+	err = refreshTokenStore.RotateRefreshToken(ctx, family, claims.Jti, jti, time.Unix(token.RefreshExp, 0))
+	if errors.Is(err, ErrRefreshTokenReused) && revocationStore != nil {
+		if err := revocationStore.RevokeFamily(ctx, family, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// newTokenPair returns an access token and a refresh token of family for op, and the ID of the refresh token.
+func (a *Authenticator) newTokenPair(op *CreateTokenOption, family string) (*JWTToken, string, error) {
+	a.mu.RLock()
+	accessDuration, refreshDuration := a.accessDuration, a.refreshDuration
+	a.mu.RUnlock()
+	if op.ExpireIn != nil && *op.ExpireIn > 0 {
+		accessDuration = *op.ExpireIn
+	}
+
+	clms, iat, exp := a.newClaims(op, accessDuration)
+	clms["fam"] = family
+	accessToken, err := a.signClaims(clms)
+	if err != nil {
+		return nil, "", err
+	}
+	token := &JWTToken{
+		AccessToken: accessToken,
+		ID:          clms["jti"].(string),
+		Iat:         iat,
+		Exp:         exp,
+	}
+
+	clms, _, exp = a.newClaims(op, refreshDuration)
+	clms["typ"] = tokenTypeRefresh
+	clms["fam"] = family
+	if token.RefreshToken, err = a.signClaims(clms); err != nil {
+		return nil, "", err
+	}
+	token.RefreshExp = exp
+	return token, clms["jti"].(string), nil
+}
+
+func ParseRefreshJWT(refreshToken string) (*TokenClaims, error) {
+	return defaultAuthenticator.ParseRefreshJWT(refreshToken)
+}
+
+// ParseRefreshJWT parses and validates a refresh token, e.g. to revoke it with RevokeJWT on logout.
+// It returns ErrNotRefreshToken for access tokens.
+func (a *Authenticator) ParseRefreshJWT(refreshToken string) (*TokenClaims, error) {
+	claims, err := a.parseJWT(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	family, _ := claims.Claims["fam"].(string)
+	if typ, _ := claims.Claims["typ"].(string); typ != tokenTypeRefresh || family == "" {
+		return nil, ErrNotRefreshToken
+	}
+	return claims, nil
+}
+
+func RevokeJWT(ctx context.Context, claims *TokenClaims) error {
+	return defaultAuthenticator.RevokeJWT(ctx, claims)
+}
+
+// RevokeJWT revokes the token of claims, and the refresh token family of refresh tokens.
+// It returns ErrNoTokenID for tokens without jti claim, which cannot be revoked by ID.
+func (a *Authenticator) RevokeJWT(ctx context.Context, claims *TokenClaims) error {
+	if claims.Jti == "" {
+		return ErrNoTokenID
+	}
+	revocationStore, refreshTokenStore := a.stores()
+	// Access tokens of a family have the fam claim as well, revoking them keeps the family.
+	typ, _ := claims.Claims["typ"].(string)
+	if family, _ := claims.Claims["fam"].(string); typ == tokenTypeRefresh && family != "" && refreshTokenStore != nil {
+		if err := refreshTokenStore.DeleteRefreshTokens(ctx, family); err != nil {
+			return err
+		}
+	}
+	if revocationStore == nil {
+		return ErrNoRevocationStore
+	}
+	var exp time.Time
+	if claims.Exp != 0 {
+		exp = time.Unix(claims.Exp, 0)
+	}
+	return revocationStore.Revoke(ctx, claims.Jti, exp)
+}
+
+func LogoutAll(ctx context.Context, sub string) error {
+	return defaultAuthenticator.LogoutAll(ctx, sub)
+}
+
+// LogoutAll revokes all access and refresh tokens of sub issued until now.
+func (a *Authenticator) LogoutAll(ctx context.Context, sub string) error {
+	revocationStore, _ := a.stores()
+	if revocationStore == nil {
+		return ErrNoRevocationStore
+	}
+	return revocationStore.RevokeSubject(ctx, sub, time.Now())
+}
+
+// checkRevoked returns ErrTokenRevoked if the token of claims is revoked.
+func (a *Authenticator) checkRevoked(ctx context.Context, claims *TokenClaims) error {
+	revocationStore, _ := a.stores()
+	if revocationStore == nil {
+		return nil
+	}
+	if claims.Jti != "" {
+		revoked, err := revocationStore.IsRevoked(ctx, claims.Jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	if claims.Sub != "" {
+		t, err := revocationStore.SubjectRevokeTime(ctx, claims.Sub)
+		if err != nil {
+			return err
+		}
+		if !t.IsZero() && claims.Iat <= t.Unix() {
+			return ErrTokenRevoked
+		}
+	}
+	if family, _ := claims.Claims["fam"].(string); family != "" {
+		t, err := revocationStore.FamilyRevokeTime(ctx, family)
+		if err != nil {
+			return err
+		}
+		if !t.IsZero() && claims.Iat <= t.Unix() {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+func isReservedClaim(name string) bool {
+	switch name {
+	case "uid", "sub", "iat", "exp", "nbf", "jti", "iss", "aud", "typ", "fam":
+		return true
+	}
+	return false
+}
+
+// MemoryStore is an in-memory RevocationStore and RefreshTokenStore for tests and single instance services.
+type MemoryStore struct {
+	mu              sync.Mutex
+	revoked         map[string]time.Time
+	subjects        map[string]time.Time
+	revokedFamilies map[string]time.Time
+	families        map[string]memoryFamily
+}
+
+type memoryFamily struct {
+	current string
+	exp     time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		revoked:         map[string]time.Time{},
+		subjects:        map[string]time.Time{},
+		revokedFamilies: map[string]time.Time{},
+		families:        map[string]memoryFamily{},
+	}
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, e := range s.revoked {
+		if !e.IsZero() && e.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[jti] = exp
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *MemoryStore) RevokeSubject(ctx context.Context, sub string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.subjects[sub]) {
+		s.subjects[sub] = t
+	}
+	return nil
+}
+
+func (s *MemoryStore) SubjectRevokeTime(ctx context.Context, sub string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subjects[sub], nil
+}
+
+func (s *MemoryStore) RevokeFamily(ctx context.Context, family string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.revokedFamilies[family]) {
+		s.revokedFamilies[family] = t
+	}
+	return nil
+}
+
+func (s *MemoryStore) FamilyRevokeTime(ctx context.Context, family string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revokedFamilies[family], nil
+}
+
+func (s *MemoryStore) SaveRefreshToken(ctx context.Context, family string, jti string, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, f := range s.families {
+		if f.exp.Before(now) {
+			delete(s.families, id)
+		}
+	}
+	s.families[family] = memoryFamily{current: jti, exp: exp}
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, family string, old string, new string, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.families[family]
+	if !ok {
+		return ErrRefreshTokenReused
+	}
+	if f.current != old {
+		delete(s.families, family)
+		return ErrRefreshTokenReused
+	}
+	s.families[family] = memoryFamily{current: new, exp: exp}
+	return nil
+}
+
+func (s *MemoryStore) DeleteRefreshTokens(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.families, family)
+	return nil
+}
@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newRefreshAuthenticator(t *testing.T, op Option) (*Authenticator, *MemoryStore) {
+	t.Helper()
+	store := NewMemoryStore()
+	op.JwtSecret = "secret"
+	op.RevocationStore = store
+	op.RefreshTokenStore = store
+	a, err := New(op)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return a, store
+}
+
+func bearer(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func verifyCode(a *Authenticator, token string) codes.Code {
+	_, err := a.VerifyJWTToken(bearer(token))
+	return status.Code(err)
+}
+
+func TestCreateJWTWithRefresh(t *testing.T) {
+	ctx := context.Background()
+	op := &CreateTokenOption{UID: 1, Sub: "user", Claims: map[string]string{"role": "admin"}}
+
+	t.Run("durations", func(t *testing.T) {
+		accessDuration := 5 * time.Minute
+		tests := []struct {
+			name string
+			op   Option
+			want time.Duration
+		}{
+			{"default", Option{}, DefaultAccessDuration},
+			{"access duration", Option{AccessDuration: &accessDuration}, accessDuration},
+		}
+		for _, tt := range tests {
+			a, _ := newRefreshAuthenticator(t, tt.op)
+			token, err := a.CreateJWTWithRefresh(ctx, op)
+			if err != nil {
+				t.Fatalf("%s: CreateJWTWithRefresh() error = %v", tt.name, err)
+			}
+			if got := time.Duration(token.Exp-token.Iat) * time.Second; got != tt.want {
+				t.Errorf("%s: access token duration = %v, want %v", tt.name, got, tt.want)
+			}
+			// The refresh token may be created in the next second
+			if got := time.Duration(token.RefreshExp-token.Iat) * time.Second; got < DefaultRefreshDuration || got > DefaultRefreshDuration+time.Second {
+				t.Errorf("%s: refresh token duration = %v, want %v", tt.name, got, DefaultRefreshDuration)
+			}
+		}
+	})
+
+	t.Run("no store", func(t *testing.T) {
+		a, err := New(Option{JwtSecret: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.CreateJWTWithRefresh(ctx, op); !errors.Is(err, ErrNoRefreshTokenStore) {
+			t.Errorf("CreateJWTWithRefresh() error = %v, want %v", err, ErrNoRefreshTokenStore)
+		}
+	})
+}
+
+func TestRefreshJWT(t *testing.T) {
+	ctx := context.Background()
+	op := &CreateTokenOption{UID: 1, Sub: "user", Claims: map[string]string{"role": "admin"}}
+
+	t.Run("rotation", func(t *testing.T) {
+		a, _ := newRefreshAuthenticator(t, Option{})
+		first, err := a.CreateJWTWithRefresh(ctx, op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := a.RefreshJWT(ctx, first.RefreshToken)
+		if err != nil {
+			t.Fatalf("RefreshJWT() error = %v", err)
+		}
+		if second.RefreshToken == first.RefreshToken || second.ID == first.ID {
+			t.Error("RefreshJWT() returned the same tokens")
+		}
+		u, err := a.ParseJWT(second.AccessToken)
+		if err != nil || u.UID != 1 || u.Sub != "user" || u.Claims["role"] != "admin" {
+			t.Errorf("ParseJWT() = %+v, %v, want the claims of the first token", u, err)
+		}
+		if code := verifyCode(a, second.AccessToken); code != codes.OK {
+			t.Errorf("VerifyJWTToken() code = %v, want %v", code, codes.OK)
+		}
+		if _, err := a.RefreshJWT(ctx, second.RefreshToken); err != nil {
+			t.Errorf("RefreshJWT() error = %v", err)
+		}
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		a, _ := newRefreshAuthenticator(t, Option{})
+		first, _ := a.CreateJWTWithRefresh(ctx, op)
+		second, err := a.RefreshJWT(ctx, first.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.RefreshJWT(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("RefreshJWT() error = %v, want %v", err, ErrRefreshTokenReused)
+		}
+		// The whole family is revoked, including the access tokens already issued
+		for _, token := range []string{first.AccessToken, second.AccessToken} {
+			if code := verifyCode(a, token); code != codes.Unauthenticated {
+				t.Errorf("VerifyJWTToken() code = %v, want %v", code, codes.Unauthenticated)
+			}
+		}
+		if _, err := a.RefreshJWT(ctx, second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("RefreshJWT() error = %v, want %v", err, ErrTokenRevoked)
+		}
+
+		other, _ := a.CreateJWTWithRefresh(ctx, op)
+		if code := verifyCode(a, other.AccessToken); code != codes.OK {
+			t.Errorf("VerifyJWTToken() code = %v for another family, want %v", code, codes.OK)
+		}
+	})
+
+	t.Run("token types", func(t *testing.T) {
+		a, _ := newRefreshAuthenticator(t, Option{})
+		token, _ := a.CreateJWTWithRefresh(ctx, op)
+		if _, err := a.RefreshJWT(ctx, token.AccessToken); !errors.Is(err, ErrNotRefreshToken) {
+			t.Errorf("RefreshJWT(access token) error = %v, want %v", err, ErrNotRefreshToken)
+		}
+		if code := verifyCode(a, token.RefreshToken); code != codes.Unauthenticated {
+			t.Errorf("VerifyJWTToken(refresh token) code = %v, want %v", code, codes.Unauthenticated)
+		}
+		if u, err := a.ParseJWT(token.RefreshToken); !errors.Is(err, ErrRefreshTokenAsAccess) {
+			t.Errorf("ParseJWT(refresh token) = %+v, %v, want %v", u, err, ErrRefreshTokenAsAccess)
+		}
+		if _, _, err := ParseJWTAs[map[string]interface{}](a, token.RefreshToken); !errors.Is(err, ErrRefreshTokenAsAccess) {
+			t.Errorf("ParseJWTAs(refresh token) error = %v, want %v", err, ErrRefreshTokenAsAccess)
+		}
+		if _, err := a.ParseRefreshJWT(token.AccessToken); !errors.Is(err, ErrNotRefreshToken) {
+			t.Errorf("ParseRefreshJWT(access token) error = %v, want %v", err, ErrNotRefreshToken)
+		}
+		if u, err := a.ParseRefreshJWT(token.RefreshToken); err != nil || u.Sub != "user" {
+			t.Errorf("ParseRefreshJWT() = %+v, %v, want user", u, err)
+		}
+	})
+}
+
+func TestRevokeJWT(t *testing.T) {
+	ctx := context.Background()
+	op := &CreateTokenOption{UID: 1, Sub: "user"}
+
+	t.Run("access token", func(t *testing.T) {
+		a, _ := newRefreshAuthenticator(t, Option{})
+		token, _ := a.CreateJWTWithRefresh(ctx, op)
+		other, _ := a.CreateJWT(op)
+		claims, _ := a.ParseJWT(token.AccessToken)
+		if err := a.RevokeJWT(ctx, claims); err != nil {
+			t.Fatalf("RevokeJWT() error = %v", err)
+		}
+		if code := verifyCode(a, token.AccessToken); code != codes.Unauthenticated {
+			t.Errorf("VerifyJWTToken() code = %v, want %v", code, codes.Unauthenticated)
+		}
+		if code := verifyCode(a, other.AccessToken); code != codes.OK {
+			t.Errorf("VerifyJWTToken(other) code = %v, want %v", code, codes.OK)
+		}
+		if _, err := a.RefreshJWT(ctx, token.RefreshToken); err != nil {
+			t.Errorf("RefreshJWT() error = %v, the refresh token is not revoked", err)
+		}
+	})
+
+	t.Run("refresh token", func(t *testing.T) {
+		a, _ := newRefreshAuthenticator(t, Option{})
+		token, _ := a.CreateJWTWithRefresh(ctx, op)
+		claims, _ := a.ParseRefreshJWT(token.RefreshToken)
+		if err := a.RevokeJWT(ctx, claims); err != nil {
+			t.Fatalf("RevokeJWT() error = %v", err)
+		}
+		if _, err := a.RefreshJWT(ctx, token.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("RefreshJWT() error = %v, want %v", err, ErrTokenRevoked)
+		}
+	})
+
+	t.Run("no exp", func(t *testing.T) {
+		a, store := newRefreshAuthenticator(t, Option{})
+		if err := a.RevokeJWT(ctx, &TokenClaims{Jti: "no exp", Sub: "user"}); err != nil {
+			t.Fatalf("RevokeJWT() error = %v", err)
+		}
+		_ = store.Revoke(ctx, "other", time.Now().Add(-time.Second))
+		if revoked, _ := store.IsRevoked(ctx, "no exp"); !revoked {
+			t.Error("IsRevoked() = false after the next revocation, want true")
+		}
+	})
+
+	t.Run("no id", func(t *testing.T) {
+		a, store := newRefreshAuthenticator(t, Option{})
+		if err := a.RevokeJWT(ctx, &TokenClaims{Sub: "user"}); !errors.Is(err, ErrNoTokenID) {
+			t.Errorf("RevokeJWT() error = %v, want %v", err, ErrNoTokenID)
+		}
+		if revoked, _ := store.IsRevoked(ctx, ""); revoked {
+			t.Error("IsRevoked(\"\") = true, want false")
+		}
+	})
+
+	t.Run("no store", func(t *testing.T) {
+		a, err := New(Option{JwtSecret: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _ := a.CreateJWT(op)
+		claims, _ := a.ParseJWT(token.AccessToken)
+		if err := a.RevokeJWT(ctx, claims); !errors.Is(err, ErrNoRevocationStore) {
+			t.Errorf("RevokeJWT() error = %v, want %v", err, ErrNoRevocationStore)
+		}
+	})
+}
+
+func TestLogoutAll(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newRefreshAuthenticator(t, Option{})
+	token, _ := a.CreateJWTWithRefresh(ctx, &CreateTokenOption{UID: 1, Sub: "user"})
+	other, _ := a.CreateJWT(&CreateTokenOption{UID: 2, Sub: "other"})
+
+	// The token is issued in the second of the logout, it is revoked anyway
+	if err := a.LogoutAll(ctx, "user"); err != nil {
+		t.Fatalf("LogoutAll() error = %v", err)
+	}
+	if code := verifyCode(a, token.AccessToken); code != codes.Unauthenticated {
+		t.Errorf("VerifyJWTToken() code = %v, want %v", code, codes.Unauthenticated)
+	}
+	if _, err := a.RefreshJWT(ctx, token.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("RefreshJWT() error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if code := verifyCode(a, other.AccessToken); code != codes.OK {
+		t.Errorf("VerifyJWTToken(other) code = %v, want %v", code, codes.OK)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("revocation", func(t *testing.T) {
+		s := NewMemoryStore()
+		_ = s.Revoke(ctx, "no exp", time.Time{})
+		_ = s.Revoke(ctx, "expired", now.Add(-time.Second))
+		_ = s.Revoke(ctx, "jti", now.Add(time.Hour))
+		if revoked, err := s.IsRevoked(ctx, "jti"); err != nil || !revoked {
+			t.Errorf("IsRevoked(jti) = %v, %v, want true", revoked, err)
+		}
+		// Expired tokens are forgotten by the next revocation, tokens without expiry are kept
+		if revoked, _ := s.IsRevoked(ctx, "expired"); revoked {
+			t.Error("IsRevoked(expired) = true, want false")
+		}
+		if revoked, _ := s.IsRevoked(ctx, "no exp"); !revoked {
+			t.Error("IsRevoked(no exp) = false, want true")
+		}
+
+		_ = s.RevokeSubject(ctx, "user", now)
+		_ = s.RevokeSubject(ctx, "user", now.Add(-time.Hour))
+		if got, _ := s.SubjectRevokeTime(ctx, "user"); !got.Equal(now) {
+			t.Errorf("SubjectRevokeTime() = %v, want %v", got, now)
+		}
+		_ = s.RevokeFamily(ctx, "family", now)
+		_ = s.RevokeFamily(ctx, "family", now.Add(-time.Hour))
+		if got, _ := s.FamilyRevokeTime(ctx, "family"); !got.Equal(now) {
+			t.Errorf("FamilyRevokeTime() = %v, want %v", got, now)
+		}
+		if got, _ := s.FamilyRevokeTime(ctx, "other"); !got.IsZero() {
+			t.Errorf("FamilyRevokeTime(other) = %v, want zero time", got)
+		}
+	})
+
+	t.Run("refresh tokens", func(t *testing.T) {
+		s := NewMemoryStore()
+		exp := now.Add(time.Hour)
+		_ = s.SaveRefreshToken(ctx, "family", "first", exp)
+		if err := s.RotateRefreshToken(ctx, "family", "first", "second", exp); err != nil {
+			t.Fatalf("RotateRefreshToken() error = %v", err)
+		}
+		if err := s.RotateRefreshToken(ctx, "family", "first", "third", exp); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("RotateRefreshToken(first) error = %v, want %v", err, ErrRefreshTokenReused)
+		}
+		// The family is deleted on reuse
+		if err := s.RotateRefreshToken(ctx, "family", "second", "third", exp); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("RotateRefreshToken(second) error = %v, want %v", err, ErrRefreshTokenReused)
+		}
+
+		_ = s.SaveRefreshToken(ctx, "other", "first", exp)
+		_ = s.DeleteRefreshTokens(ctx, "other")
+		if err := s.RotateRefreshToken(ctx, "other", "first", "second", exp); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("RotateRefreshToken() error = %v after DeleteRefreshTokens, want %v", err, ErrRefreshTokenReused)
+		}
+	})
+}
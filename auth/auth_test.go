@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticator_Concurrent(t *testing.T) {
+	authenticators := map[string]*Authenticator{}
+	for _, name := range []string{"first", "second"} {
+		a, err := New(Option{JwtSecret: name + " secret"})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		authenticators[name] = a
+	}
+
+	var wg sync.WaitGroup
+	for name, a := range authenticators {
+		other := authenticators["first"]
+		if name == "first" {
+			other = authenticators["second"]
+		}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(name string, a, other *Authenticator) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					token, err := a.CreateJWT(&CreateTokenOption{UID: 1, Sub: name})
+					if err != nil {
+						t.Errorf("CreateJWT() error = %v", err)
+						return
+					}
+					if u, err := a.ParseJWT(token.AccessToken); err != nil || u.Sub != name {
+						t.Errorf("%s ParseJWT() = %+v, %v, want %s", name, u, err, name)
+					}
+					if code := verifyCode(other, token.AccessToken); code != codes.Unauthenticated {
+						t.Errorf("VerifyJWTToken() of the other authenticator code = %v, want %v", code, codes.Unauthenticated)
+					}
+					if j%5 == 0 {
+						a.AddTokenValidator(func(context.Context, *TokenClaims) error { return nil })
+					}
+				}
+			}(name, a, other)
+		}
+	}
+	wg.Wait()
+}
+
+func TestAuthenticator_Init(t *testing.T) {
+	errRejected := status.Error(codes.PermissionDenied, "rejected")
+	calls := 0
+	counter := func(context.Context, *TokenClaims) error {
+		calls++
+		return nil
+	}
+	apiKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+	}
+
+	a := &Authenticator{}
+	a.AddTokenValidator(counter)
+	a.AddAPIKey("added")
+	for i := 0; i < 3; i++ {
+		if err := a.Init(Option{JwtSecret: "secret", TokenValidators: []TokenValidator{counter}, APIKeys: []string{"option"}}); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+	}
+	token, err := a.CreateJWT(&CreateTokenOption{UID: 1, Sub: "user"})
+	if err != nil {
+		t.Fatalf("CreateJWT() error = %v", err)
+	}
+	if code := verifyCode(a, token.AccessToken); code != codes.OK {
+		t.Fatalf("VerifyJWTToken() code = %v, want %v", code, codes.OK)
+	}
+	if calls != 2 {
+		t.Errorf("validators called %d times, want 2 after repeated Init", calls)
+	}
+	for _, key := range []string{"option", "added"} {
+		if _, err := a.VerifyAPIKey(apiKey(key)); err != nil {
+			t.Errorf("VerifyAPIKey(%s) error = %v", key, err)
+		}
+	}
+
+	if err := a.Init(Option{JwtSecret: "secret", TokenValidators: []TokenValidator{
+		func(context.Context, *TokenClaims) error { return errRejected },
+	}}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if _, err := a.VerifyJWTToken(bearer(token.AccessToken)); !errors.Is(err, errRejected) {
+		t.Errorf("VerifyJWTToken() error = %v, want %v", err, errRejected)
+	}
+	if _, err := a.VerifyAPIKey(apiKey("option")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("VerifyAPIKey(option) error = %v, want the key replaced by Init", err)
+	}
+	if _, err := a.VerifyAPIKey(apiKey("added")); err != nil {
+		t.Errorf("VerifyAPIKey(added) error = %v", err)
+	}
+}
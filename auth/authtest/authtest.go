@@ -0,0 +1,79 @@
+// Package authtest provides keys and a JWKS server standing in for an identity service in tests.
+package authtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ahiho/gocandy/auth"
+)
+
+const JWKSPath = "/.well-known/jwks.json"
+
+// GenerateKey returns a new signing key for alg, one of RS256, ES256 and EdDSA. It panics on errors.
+func GenerateKey(id string, alg string) *auth.Key {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	if err != nil {
+		panic(err)
+	}
+	key, err := auth.NewKey(id, private)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// JWKSServer serves a JWKS document at JWKSPath.
+type JWKSServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys []*auth.Key
+}
+
+// NewJWKSServer starts a server serving the JWKS document of keys. Close it after use.
+func NewJWKSServer(keys ...*auth.Key) *JWKSServer {
+	s := &JWKSServer{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc(JWKSPath, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		jwks := auth.NewJWKS(s.keys...)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jwks)
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// JWKSURL returns the URL of the JWKS document.
+func (s *JWKSServer) JWKSURL() string {
+	return s.Server.URL + JWKSPath
+}
+
+// SetKeys replaces the keys of the document, e.g. to simulate a key rotation.
+func (s *JWKSServer) SetKeys(keys ...*auth.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
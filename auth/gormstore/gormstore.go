@@ -0,0 +1,161 @@
+// Package gormstore implements the auth RevocationStore and RefreshTokenStore on top of gorm,
+// sharing revocations between the instances of a service. It is a separate module, so that auth
+// does not depend on gorm. Migrate its tables with
+//
+//	db.AutoMigrate(gormstore.Models()...)
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ahiho/gocandy/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedToken is a token revoked by ID.
+type RevokedToken struct {
+	Jti string `gorm:"primaryKey;size:64"`
+	// ExpireTime is the expiry of the token, the record can be purged after it. It is nil for tokens
+	// without expiry, which are never purged.
+	ExpireTime *time.Time `gorm:"index;type:DATETIME(6)"`
+}
+
+func (RevokedToken) TableName() string {
+	return "auth_revoked_tokens"
+}
+
+// RevokedSubject revokes the tokens of a subject issued before RevokeTime.
+type RevokedSubject struct {
+	Sub        string    `gorm:"primaryKey;size:255"`
+	RevokeTime time.Time `gorm:"type:DATETIME(6)"`
+}
+
+func (RevokedSubject) TableName() string {
+	return "auth_revoked_subjects"
+}
+
+// RevokedFamily revokes the tokens of a refresh token family issued until RevokeTime.
+type RevokedFamily struct {
+	Family     string    `gorm:"primaryKey;size:64"`
+	RevokeTime time.Time `gorm:"type:DATETIME(6)"`
+}
+
+func (RevokedFamily) TableName() string {
+	return "auth_revoked_families"
+}
+
+// RefreshTokenFamily is the current token of a refresh token family.
+type RefreshTokenFamily struct {
+	Family     string    `gorm:"primaryKey;size:64"`
+	CurrentJti string    `gorm:"size:64;not null"`
+	ExpireTime time.Time `gorm:"index;type:DATETIME(6)"`
+}
+
+func (RefreshTokenFamily) TableName() string {
+	return "auth_refresh_token_families"
+}
+
+// Models returns the models of the store for migrations.
+func Models() []interface{} {
+	return []interface{}{&RevokedToken{}, &RevokedSubject{}, &RevokedFamily{}, &RefreshTokenFamily{}}
+}
+
+type Store struct {
+	db *gorm.DB
+}
+
+var (
+	_ auth.RevocationStore   = (*Store)(nil)
+	_ auth.RefreshTokenStore = (*Store)(nil)
+)
+
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	token := &RevokedToken{Jti: jti}
+	if !exp.IsZero() {
+		token.ExpireTime = &exp
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (s *Store) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+func (s *Store) RevokeSubject(ctx context.Context, sub string, t time.Time) error {
+	return s.db.WithContext(ctx).Clauses(keepLatestRevokeTime(t)).Create(&RevokedSubject{Sub: sub, RevokeTime: t}).Error
+}
+
+// keepLatestRevokeTime updates the revoke_time of an existing record to t unless it is later already,
+// so that a delayed revocation cannot shorten a later one, as in auth.MemoryStore.
+func keepLatestRevokeTime(t time.Time) clause.OnConflict {
+	return clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{"revoke_time": gorm.Expr("GREATEST(revoke_time, ?)", t)}),
+	}
+}
+
+func (s *Store) SubjectRevokeTime(ctx context.Context, sub string) (time.Time, error) {
+	subjects := []RevokedSubject{}
+	if err := s.db.WithContext(ctx).Where("sub = ?", sub).Limit(1).Find(&subjects).Error; err != nil {
+		return time.Time{}, err
+	}
+	if len(subjects) == 0 {
+		return time.Time{}, nil
+	}
+	return subjects[0].RevokeTime, nil
+}
+
+func (s *Store) RevokeFamily(ctx context.Context, family string, t time.Time) error {
+	return s.db.WithContext(ctx).Clauses(keepLatestRevokeTime(t)).Create(&RevokedFamily{Family: family, RevokeTime: t}).Error
+}
+
+func (s *Store) FamilyRevokeTime(ctx context.Context, family string) (time.Time, error) {
+	families := []RevokedFamily{}
+	if err := s.db.WithContext(ctx).Where("family = ?", family).Limit(1).Find(&families).Error; err != nil {
+		return time.Time{}, err
+	}
+	if len(families) == 0 {
+		return time.Time{}, nil
+	}
+	return families[0].RevokeTime, nil
+}
+
+func (s *Store) SaveRefreshToken(ctx context.Context, family string, jti string, exp time.Time) error {
+	return s.db.WithContext(ctx).Create(&RefreshTokenFamily{Family: family, CurrentJti: jti, ExpireTime: exp}).Error
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, family string, old string, new string, exp time.Time) error {
+	res := s.db.WithContext(ctx).Model(&RefreshTokenFamily{}).
+		Where("family = ? AND current_jti = ?", family, old).
+		Updates(map[string]interface{}{"current_jti": new, "expire_time": exp})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := s.DeleteRefreshTokens(ctx, family); err != nil {
+			return err
+		}
+		return auth.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (s *Store) DeleteRefreshTokens(ctx context.Context, family string) error {
+	return s.db.WithContext(ctx).Where("family = ?", family).Delete(&RefreshTokenFamily{}).Error
+}
+
+// Purge deletes the records of tokens expired before t. Revoked tokens without expiry are kept.
+func (s *Store) Purge(ctx context.Context, t time.Time) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("expire_time < ?", t).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expire_time < ?", t).Delete(&RefreshTokenFamily{}).Error
+}
@@ -0,0 +1,169 @@
+package gormstore
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahiho/gocandy/auth"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return New(db), mock
+}
+
+func TestStore_Revocation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("revoke", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auth_revoked_tokens` (`jti`,`expire_time`) VALUES (?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs("jti", now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `auth_revoked_tokens` WHERE jti = ?")).WithArgs("jti").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		if err := s.Revoke(ctx, "jti", now); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		if revoked, err := s.IsRevoked(ctx, "jti"); err != nil || !revoked {
+			t.Errorf("IsRevoked() = %v, %v, want true", revoked, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("revoke without expiry", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auth_revoked_tokens` (`jti`,`expire_time`) VALUES (?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs("jti", nil).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := s.Revoke(ctx, "jti", time.Time{}); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("subject", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auth_revoked_subjects` (`sub`,`revoke_time`) VALUES (?,?) "+
+			"ON DUPLICATE KEY UPDATE `revoke_time`=GREATEST(revoke_time, ?)")).
+			WithArgs("user", now, now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `auth_revoked_subjects` WHERE sub = ? LIMIT 1")).WithArgs("user").
+			WillReturnRows(sqlmock.NewRows([]string{"sub", "revoke_time"}).AddRow("user", now))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `auth_revoked_subjects` WHERE sub = ? LIMIT 1")).WithArgs("other").
+			WillReturnRows(sqlmock.NewRows([]string{"sub", "revoke_time"}))
+
+		if err := s.RevokeSubject(ctx, "user", now); err != nil {
+			t.Fatalf("RevokeSubject() error = %v", err)
+		}
+		if got, err := s.SubjectRevokeTime(ctx, "user"); err != nil || !got.Equal(now) {
+			t.Errorf("SubjectRevokeTime() = %v, %v, want %v", got, err, now)
+		}
+		if got, err := s.SubjectRevokeTime(ctx, "other"); err != nil || !got.IsZero() {
+			t.Errorf("SubjectRevokeTime(other) = %v, %v, want zero time", got, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("family", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auth_revoked_families` (`family`,`revoke_time`) VALUES (?,?) "+
+			"ON DUPLICATE KEY UPDATE `revoke_time`=GREATEST(revoke_time, ?)")).
+			WithArgs("family", now, now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `auth_revoked_families` WHERE family = ? LIMIT 1")).WithArgs("family").
+			WillReturnRows(sqlmock.NewRows([]string{"family", "revoke_time"}).AddRow("family", now))
+
+		if err := s.RevokeFamily(ctx, "family", now); err != nil {
+			t.Fatalf("RevokeFamily() error = %v", err)
+		}
+		if got, err := s.FamilyRevokeTime(ctx, "family"); err != nil || !got.Equal(now) {
+			t.Errorf("FamilyRevokeTime() = %v, %v, want %v", got, err, now)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestStore_RefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+	rotate := regexp.QuoteMeta("UPDATE `auth_refresh_token_families` SET `current_jti`=?,`expire_time`=? " +
+		"WHERE family = ? AND current_jti = ?")
+
+	t.Run("rotate", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `auth_refresh_token_families` (`family`,`current_jti`,`expire_time`) VALUES (?,?,?)")).
+			WithArgs("family", "first", exp).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(rotate).WithArgs("second", exp, "family", "first").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := s.SaveRefreshToken(ctx, "family", "first", exp); err != nil {
+			t.Fatalf("SaveRefreshToken() error = %v", err)
+		}
+		if err := s.RotateRefreshToken(ctx, "family", "first", "second", exp); err != nil {
+			t.Errorf("RotateRefreshToken() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(rotate).WithArgs("third", exp, "family", "first").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `auth_refresh_token_families` WHERE family = ?")).WithArgs("family").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := s.RotateRefreshToken(ctx, "family", "first", "third", exp); !errors.Is(err, auth.ErrRefreshTokenReused) {
+			t.Errorf("RotateRefreshToken() error = %v, want %v", err, auth.ErrRefreshTokenReused)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestStore_Purge(t *testing.T) {
+	now := time.Now()
+	s, mock := newMockStore(t)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `auth_revoked_tokens` WHERE expire_time < ?")).WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `auth_refresh_token_families` WHERE expire_time < ?")).WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.Purge(context.Background(), now); err != nil {
+		t.Errorf("Purge() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,7 @@
 package fieldmask
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/iancoleman/strcase"
@@ -13,6 +14,8 @@ import (
 // MaxSize is the hard limit on the number of fields in a single field mask.
 const MaxSize = 32
 
+var ErrTooManyFields = errors.New("too many fields in field mask")
+
 // Mask represents a list of fields in a resource.
 type Mask struct {
 	// Fields included in the mask, in snake case.
@@ -24,7 +27,7 @@ type Mask struct {
 // New returns a new Mask created from a protobuf mask.
 func New(mask *field_mask.FieldMask) (*Mask, error) {
 	if len(mask.Paths) > MaxSize {
-		return nil, fmt.Errorf("number of fields is %d, maximum allowed is %d", len(mask.Paths), MaxSize)
+		return nil, fmt.Errorf("%w: number of fields is %d, maximum allowed is %d", ErrTooManyFields, len(mask.Paths), MaxSize)
 	}
 
 	fields := make([]string, len(mask.Paths))
@@ -53,14 +53,38 @@ func NewSQLAdaptor(fieldMappings map[string]string, defaultFields map[string]Par
 	return &sa
 }
 
+// ErrInvalidQuery is matched by errors.Is for all errors returned by Parse for an invalid query.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// queryError marks an error caused by an invalid query, keeping its message.
+type queryError struct {
+	err error
+}
+
+func (e *queryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *queryError) Unwrap() error {
+	return e.err
+}
+
+func (e *queryError) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
 // Parse takes a string goven query and returns a SqlResponse that can be executed against your database.
 func (s *SQLAdaptor) Parse(str string) (*SQLResponse, error) {
 	newParser := parser.NewParser(str)
 	node, err := newParser.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("query could not be parsed: %w", err)
+		return nil, &queryError{fmt.Errorf("query could not be parsed: %w", err)}
+	}
+	res, err := s.parseNodeToSQL(node)
+	if err != nil {
+		return nil, &queryError{err}
 	}
-	return s.parseNodeToSQL(node)
+	return res, nil
 }
 
 func (s *SQLAdaptor) parseNodeToSQL(node parser.Node) (*SQLResponse, error) {
@@ -2,6 +2,7 @@ package sql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -95,6 +96,7 @@ func TestSqlAdaptor(t *testing.T) {
 			sa := NewDefaultAdaptorFromStruct(reflect.ValueOf(&ExampleDBStruct{}))
 			_, err := sa.Parse(testCase.test)
 			g.Expect(err).ToNot(BeNil(), fmt.Sprintf("failed case: %s", testCase.test))
+			g.Expect(errors.Is(err, ErrInvalidQuery)).To(BeTrue(), fmt.Sprintf("failed case: %s", testCase.test))
 		}
 	})
 	t.Run("test FieldParseValidatorFromStruct", func(t *testing.T) {
@@ -0,0 +1,361 @@
+package gormx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ahiho/gocandy/fieldmask"
+	"github.com/ahiho/gocandy/idgen"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// BulkRequest controls BulkInsert and BulkUpsert.
+type BulkRequest struct {
+	// BatchSize is the number of rows written per statement. Defaults to DefaultBatchSize.
+	BatchSize int
+	// Mask restricts the columns updated for existing rows in BulkUpsert.
+	// Defaults to all updatable fields, output only fields are never updated.
+	Mask *fieldmask.Mask
+	// Keys are the fields identifying existing rows in BulkUpsert, they must form a unique key.
+	// Defaults to the primary key.
+	Keys []string
+}
+
+// RowOutcome is the outcome of writing a single row in BulkInsert or BulkUpsert.
+type RowOutcome int
+
+const (
+	// RowNotWritten means the row was not written because writing an earlier batch failed.
+	RowNotWritten RowOutcome = iota
+	// RowInserted means the row was inserted.
+	RowInserted
+	// RowUpdated means the row existed and was updated.
+	RowUpdated
+	// RowConflict means the row existed with a different version than the one given and was skipped.
+	RowConflict
+)
+
+// BulkResult reports the outcome of BulkInsert and BulkUpsert.
+type BulkResult struct {
+	// Outcomes holds the outcome of each row, in the order of the input.
+	Outcomes []RowOutcome
+
+	Inserted  int
+	Updated   int
+	Conflicts int
+}
+
+var (
+	ErrDuplicateRows = errors.New("duplicate rows")
+)
+
+// BulkInsert inserts rows in batches, each in its own transaction.
+// New IDs from idgen are assigned to rows without one and the version of new rows is set to 1.
+// The returned result is valid even if an error is returned.
+func BulkInsert[T any](ctx context.Context, db *gorm.DB, rows []*T, req BulkRequest) (*BulkResult, error) {
+	w, err := newBulkWriter[T](db, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.run(ctx, db, rows, func(tx *gorm.DB, chunk []*T, outcomes []RowOutcome) error {
+		for i, r := range chunk {
+			w.prepareNew(ctx, r)
+			outcomes[i] = RowInserted
+		}
+		if err := w.assignIDs(ctx, chunk); err != nil {
+			return err
+		}
+		return tx.Create(&chunk).Error
+	})
+}
+
+// BulkUpsert inserts new rows and updates existing rows, identified by req.Keys, in batches using
+// INSERT ... ON DUPLICATE KEY UPDATE. Each batch runs in its own transaction.
+//
+// New rows are handled like in BulkInsert. Existing rows get the ID of the stored row and,
+// if T embeds model.Version, their version is incremented. Rows with a non-zero version which
+// differs from the stored one are skipped and reported as RowConflict.
+// Soft deleted rows are updated but stay deleted.
+// The returned result is valid even if an error is returned.
+func BulkUpsert[T any](ctx context.Context, db *gorm.DB, rows []*T, req BulkRequest) (*BulkResult, error) {
+	w, err := newBulkWriter[T](db, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.run(ctx, db, rows, func(tx *gorm.DB, chunk []*T, outcomes []RowOutcome) error {
+		existing, err := w.existing(tx, chunk)
+		if err != nil {
+			return err
+		}
+
+		write := make([]*T, 0, len(chunk))
+		for i, r := range chunk {
+			rv := reflect.ValueOf(r).Elem()
+			stored, ok := existing[w.key(ctx, rv)]
+			if !ok {
+				w.prepareNew(ctx, r)
+				outcomes[i] = RowInserted
+				write = append(write, r)
+				continue
+			}
+
+			if w.version != nil {
+				v, _ := w.version.ValueOf(ctx, rv)
+				given, ok := v.(uint64)
+				if !ok {
+					return fmt.Errorf("unsupported version type %T", v)
+				}
+				v, _ = w.version.ValueOf(ctx, stored)
+				current, ok := v.(uint64)
+				if !ok {
+					return fmt.Errorf("unsupported version type %T", v)
+				}
+				if given != 0 && given != current {
+					outcomes[i] = RowConflict
+					continue
+				}
+				if err := w.version.Set(ctx, rv, current+1); err != nil {
+					return err
+				}
+			}
+			id, _ := w.pk.ValueOf(ctx, stored)
+			if err := w.pk.Set(ctx, rv, id); err != nil {
+				return err
+			}
+			outcomes[i] = RowUpdated
+			write = append(write, r)
+		}
+		if len(write) == 0 {
+			return nil
+		}
+		if err := w.assignIDs(ctx, write); err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoUpdates: w.assignments}).Create(&write).Error
+	})
+}
+
+// bulkWriter holds the state shared by all batches of a bulk write.
+type bulkWriter[T any] struct {
+	pk          *schema.Field
+	version     *schema.Field
+	keys        []*schema.Field
+	assignments clause.Set
+	batchSize   int
+}
+
+func newBulkWriter[T any](db *gorm.DB, req BulkRequest) (*bulkWriter[T], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	w := &bulkWriter[T]{
+		pk:        stmt.Schema.PrioritizedPrimaryField,
+		version:   stmt.Schema.LookUpField(VersionColumn),
+		batchSize: req.BatchSize,
+	}
+	if w.pk == nil {
+		return nil, ErrNoPrimaryKey
+	}
+	if w.batchSize < 1 {
+		w.batchSize = DefaultBatchSize
+	}
+
+	if len(req.Keys) == 0 {
+		w.keys = []*schema.Field{w.pk}
+	}
+	for _, k := range req.Keys {
+		f := stmt.Schema.LookUpField(k)
+		if f == nil || f.DBName == "" {
+			return nil, fmt.Errorf("unknown key field %q", k)
+		}
+		w.keys = append(w.keys, f)
+	}
+
+	columns, err := updateColumns[T](stmt.Schema, req.Mask)
+	if err != nil {
+		return nil, err
+	}
+	w.assignments = clause.AssignmentColumns(columns)
+	if w.version != nil {
+		w.assignments = append(w.assignments, clause.Assignment{
+			Column: clause.Column{Name: w.version.DBName},
+			Value:  gorm.Expr("? + 1", clause.Column{Name: w.version.DBName}),
+		})
+	}
+	return w, nil
+}
+
+// run calls write for every batch of rows in its own transaction. write gets copies of the rows,
+// which are copied back once the transaction committed, so the IDs and versions assigned to the
+// rows of a rolled back batch are not kept.
+func (w *bulkWriter[T]) run(
+	ctx context.Context,
+	db *gorm.DB,
+	rows []*T,
+	write func(tx *gorm.DB, chunk []*T, outcomes []RowOutcome) error,
+) (*BulkResult, error) {
+	res := &BulkResult{Outcomes: make([]RowOutcome, len(rows))}
+	db = db.WithContext(ctx)
+
+	var err error
+	for start := 0; start < len(rows) && err == nil; start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		outcomes := res.Outcomes[start:end]
+		chunk := make([]*T, 0, end-start)
+		for _, r := range rows[start:end] {
+			c := *r
+			chunk = append(chunk, &c)
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return write(tx, chunk, outcomes)
+		})
+		if err != nil {
+			// Nothing of a rolled back batch was written
+			for i := range outcomes {
+				outcomes[i] = RowNotWritten
+			}
+			continue
+		}
+		for i, c := range chunk {
+			*rows[start+i] = *c
+		}
+	}
+
+	for _, o := range res.Outcomes {
+		switch o {
+		case RowInserted:
+			res.Inserted++
+		case RowUpdated:
+			res.Updated++
+		case RowConflict:
+			res.Conflicts++
+		}
+	}
+	return res, err
+}
+
+// existing locks and returns the stored rows matching the keys of rows, indexed by key.
+func (w *bulkWriter[T]) existing(tx *gorm.DB, rows []*T) (map[string]reflect.Value, error) {
+	ctx := tx.Statement.Context
+	seen := map[string]bool{}
+	tuples := [][]interface{}{}
+	for _, r := range rows {
+		rv := reflect.ValueOf(r).Elem()
+		if len(w.keys) == 1 && w.keys[0] == w.pk {
+			// Rows without an ID are new
+			if _, zero := w.pk.ValueOf(ctx, rv); zero {
+				continue
+			}
+		}
+		k := w.key(ctx, rv)
+		if seen[k] {
+			return nil, fmt.Errorf("%w: key %v", ErrDuplicateRows, k)
+		}
+		seen[k] = true
+
+		tuple := make([]interface{}, len(w.keys))
+		for i, f := range w.keys {
+			tuple[i], _ = f.ValueOf(ctx, rv)
+		}
+		tuples = append(tuples, tuple)
+	}
+	if len(tuples) == 0 {
+		return map[string]reflect.Value{}, nil
+	}
+
+	columns := make([]string, len(w.keys))
+	selects := []string{w.pk.DBName}
+	for i, f := range w.keys {
+		columns[i] = tx.Statement.Quote(f.DBName)
+		if f != w.pk {
+			selects = append(selects, f.DBName)
+		}
+	}
+	if w.version != nil {
+		selects = append(selects, w.version.DBName)
+	}
+
+	stored := []T{}
+	err := tx.Unscoped().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select(selects).
+		Where(fmt.Sprintf("(%s) IN ?", strings.Join(columns, ",")), tuples).
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]reflect.Value, len(stored))
+	for i := range stored {
+		rv := reflect.ValueOf(&stored[i]).Elem()
+		res[w.key(ctx, rv)] = rv
+	}
+	return res, nil
+}
+
+// key returns a string identifying the key values of rv. The values are converted to their driver
+// values first, so that the keys of the given rows match the keys of the stored rows, e.g. for times
+// in other locations.
+func (w *bulkWriter[T]) key(ctx context.Context, rv reflect.Value) string {
+	values := make([]interface{}, len(w.keys))
+	for i, f := range w.keys {
+		v, _ := f.ValueOf(ctx, rv)
+		if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+			v = dv
+		}
+		switch dv := v.(type) {
+		case time.Time:
+			v = dv.UTC().Format(time.RFC3339Nano)
+		case []byte:
+			v = string(dv)
+		}
+		values[i] = v
+	}
+	return fmt.Sprintf("%#v", values)
+}
+
+// prepareNew sets the version of a new row to 1.
+func (w *bulkWriter[T]) prepareNew(ctx context.Context, r *T) {
+	if w.version == nil {
+		return
+	}
+	rv := reflect.ValueOf(r).Elem()
+	if _, zero := w.version.ValueOf(ctx, rv); zero {
+		_ = w.version.Set(ctx, rv, uint64(1))
+	}
+}
+
+// assignIDs assigns IDs from idgen to rows without one, using a single idgen.GenIds call.
+func (w *bulkWriter[T]) assignIDs(ctx context.Context, rows []*T) error {
+	missing := []reflect.Value{}
+	for _, r := range rows {
+		rv := reflect.ValueOf(r).Elem()
+		if _, zero := w.pk.ValueOf(ctx, rv); zero {
+			missing = append(missing, rv)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for i, id := range idgen.GenIds(len(missing)) {
+		if err := w.pk.Set(ctx, missing[i], id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/ahiho/gocandy/fieldmask"
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/idgen"
+)
+
+func TestBulkInsert(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+	db, mock := newMockDB(t)
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`")).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`")).WillReturnError(errors.New("insert"))
+	mock.ExpectRollback()
+
+	rows := []*testProfile{{}, {}, {}, {}, {}}
+	got, err := BulkInsert(context.Background(), db, rows, BulkRequest{BatchSize: 2})
+	if err == nil {
+		t.Fatalf("BulkInsert() error = nil, want error")
+	}
+	want := []RowOutcome{RowInserted, RowInserted, RowInserted, RowInserted, RowNotWritten}
+	if !reflect.DeepEqual(got.Outcomes, want) || got.Inserted != 4 {
+		t.Errorf("BulkInsert() outcomes = %v, want %v", got.Outcomes, want)
+	}
+	if rows[0].ID == 0 || rows[0].ResourceVersion() != 1 {
+		t.Errorf("BulkInsert() id = %v, version = %v, want new id and version 1", rows[0].ID, rows[0].ResourceVersion())
+	}
+	if rows[4].ID != 0 || rows[4].ResourceVersion() != 0 {
+		t.Errorf("BulkInsert() id = %v, version = %v of a rolled back row, want 0, 0", rows[4].ID, rows[4].ResourceVersion())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT `id`,`email`,`version` FROM `test_profiles` WHERE (`email`) IN ((?),(?),(?)) FOR UPDATE",
+	)).WithArgs("a", "b", "c").WillReturnRows(sqlmock.NewRows([]string{"id", "email", "version"}).
+		AddRow(10, "a", 3).
+		AddRow(11, "b", 5))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`") + ".*" +
+		regexp.QuoteMeta("ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`version`=`version` + 1")).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	rows := []*testProfile{
+		{Email: "a", Name: "updated"},
+		{Email: "b", Name: "stale", Version: model.Version{Version: 4}},
+		{Email: "c", Name: "new"},
+	}
+	req := BulkRequest{Keys: []string{"email"}, Mask: &fieldmask.Mask{Fields: []string{"name"}}}
+	got, err := BulkUpsert(context.Background(), db, rows, req)
+	if err != nil {
+		t.Fatalf("BulkUpsert() error = %v", err)
+	}
+	want := []RowOutcome{RowUpdated, RowConflict, RowInserted}
+	if !reflect.DeepEqual(got.Outcomes, want) || got.Updated != 1 || got.Conflicts != 1 || got.Inserted != 1 {
+		t.Errorf("BulkUpsert() result = %+v, want outcomes %v", got, want)
+	}
+	if rows[0].ID != 10 || rows[0].ResourceVersion() != 4 {
+		t.Errorf("BulkUpsert() updated id = %v, version = %v, want 10, 4", rows[0].ID, rows[0].ResourceVersion())
+	}
+	if rows[2].ID == 0 || rows[2].ResourceVersion() != 1 {
+		t.Errorf("BulkUpsert() new id = %v, version = %v, want new id and version 1", rows[2].ID, rows[2].ResourceVersion())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBulkUpsert_duplicateRows(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	rows := []*testProfile{{Common: model.Common{ID: 1}}, {Common: model.Common{ID: 1}}}
+	_, err := BulkUpsert(context.Background(), db, rows, BulkRequest{})
+	if !errors.Is(err, ErrDuplicateRows) {
+		t.Errorf("BulkUpsert() error = %v, want %v", err, ErrDuplicateRows)
+	}
+}
+
+type testSlot struct {
+	model.Common
+	Start time.Time
+	Name  string
+}
+
+func TestBulkUpsert_timeKey(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`start` FROM `test_slots`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "start"}).AddRow(7, start))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_slots`")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// The same instant in another location, read from the clock with a monotonic reading.
+	local := time.Now().In(time.FixedZone("ICT", 7*3600))
+	local = local.Add(start.Sub(local))
+	rows := []*testSlot{{Start: local, Name: "updated"}}
+	got, err := BulkUpsert(context.Background(), db, rows, BulkRequest{Keys: []string{"start"}})
+	if err != nil {
+		t.Fatalf("BulkUpsert() error = %v", err)
+	}
+	if got.Outcomes[0] != RowUpdated || rows[0].ID != 7 {
+		t.Errorf("BulkUpsert() outcome = %v, id = %v, want %v, 7", got.Outcomes[0], rows[0].ID, RowUpdated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+type testCounter struct {
+	model.Common
+	Name    string
+	Version int32
+}
+
+func TestBulkUpsert_unsupportedVersion(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`version` FROM `test_counters`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 2))
+	mock.ExpectRollback()
+
+	rows := []*testCounter{{Common: model.Common{ID: 1}, Name: "a"}}
+	got, err := BulkUpsert(context.Background(), db, rows, BulkRequest{})
+	if err == nil || err.Error() != "unsupported version type int32" {
+		t.Errorf("BulkUpsert() error = %v, want unsupported version type", err)
+	}
+	if got.Outcomes[0] != RowNotWritten {
+		t.Errorf("BulkUpsert() outcome = %v, want %v", got.Outcomes[0], RowNotWritten)
+	}
+}
@@ -0,0 +1,34 @@
+package gormx
+
+import (
+	"errors"
+
+	"github.com/ahiho/gocandy/apperror"
+)
+
+// MySQL error numbers of the errors retried by Transaction,
+// see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// The errors of gorm, MySQL and the listing packages are translated by apperror/sqlerror,
+// which services register explicitly.
+func init() {
+	apperror.RegisterTranslator(translateError)
+}
+
+// translateError translates gormx errors caused by invalid requests and version conflicts into apperrors.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidPageToken),
+		errors.Is(err, ErrInvalidOrderBy),
+		errors.Is(err, ErrInvalidFilter),
+		errors.Is(err, ErrInvalidETag):
+		return apperror.BadRequestWithCodeE(apperror.ErrBadRequest, err.Error(), err)
+	case errors.Is(err, ErrVersionConflict):
+		return apperror.ConflictWithCodeE(apperror.ErrConflict, err.Error(), err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package gormx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ahiho/gocandy/apperror"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"order by", fmt.Errorf("%w: unknown field", ErrInvalidOrderBy), apperror.ErrBadRequest},
+		{"etag", ErrInvalidETag, apperror.ErrBadRequest},
+		{"version conflict", &VersionConflictError{Expected: 2}, apperror.ErrConflict},
+		{"other", errors.New("failed"), apperror.ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := apperror.Translate(tt.err)
+			if got := apperror.CodeOf(err); got != tt.want {
+				t.Errorf("Translate() code = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Translate() = %v, lost %v", err, tt.err)
+			}
+		})
+	}
+}
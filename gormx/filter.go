@@ -1,17 +1,24 @@
 package gormx
 
 import (
+	"errors"
+	"fmt"
+
 	filter "github.com/ahiho/gocandy/filter/adapter/sql"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
 func Filter(db *gorm.DB, filterReq string, adaptor *filter.SQLAdaptor) (*gorm.DB, error) {
 	queryResp := &filter.SQLResponse{}
 	var err error
 	if filterReq != "" {
 		queryResp, err = adaptor.Parse(filterReq)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
 		}
 	}
 
@@ -1,14 +1,34 @@
 module github.com/ahiho/gocandy/gormx
 
-go 1.18
+go 1.23
 
 require (
-	github.com/ahiho/gocandy/filter v0.0.0-20220811073839-b20055b33744
-	gorm.io/gorm v1.23.8
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/ahiho/gocandy/apperror v0.0.0-20261019111112-094aabd0d98a
+	github.com/ahiho/gocandy/fieldmask v0.0.0-20261019100950-41d50864a1d0
+	github.com/ahiho/gocandy/filter v0.0.0-20261019100950-41d50864a1d0
+	github.com/ahiho/gocandy/gormx/model v0.0.0-20220627170231-e8e96d96368c
+	github.com/ahiho/gocandy/idgen v0.0.0-20261019084858-64c64debd35b
+	github.com/ahiho/gocandy/listing v0.0.0-20261019100950-41d50864a1d0
+	github.com/ahiho/gocandy/resource v0.0.0-20220627063417-90993ef64e79
+	github.com/ahiho/gocandy/xcontext v0.0.0-20261019084858-64c64debd35b
+	github.com/go-sql-driver/mysql v1.6.0
+	gorm.io/driver/mysql v1.3.6
+	gorm.io/gorm v1.23.10
 )
 
 require (
+	github.com/bwmarrin/snowflake v0.3.1-0.20221123153919-bc74ab286f15 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.11.3 // indirect
 	github.com/iancoleman/strcase v0.2.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/net v0.0.0-20220706163947-c90051bbdb60 // indirect
+	golang.org/x/sys v0.0.0-20220610221304-9f5ed59c137d // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
+	google.golang.org/grpc v1.49.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
 )
@@ -0,0 +1,80 @@
+package gormx
+
+import (
+	"reflect"
+
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/idgen"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	idPluginName = "gormx:id"
+)
+
+// IDPlugin is a gorm plugin which assigns snowflake IDs from idgen to new models embedding model.Common
+// whose ID is not set yet. Batch creates get their IDs from a single idgen.GenIds call.
+//
+//	db.Use(&gormx.IDPlugin{})
+//
+// idgen must be initialized before the first create.
+type IDPlugin struct {
+	// Skip reports whether IDs must not be assigned to the model with schema s.
+	// Optional, IDs are assigned to all models embedding model.Common by default.
+	Skip func(s *schema.Schema) bool
+}
+
+func (p *IDPlugin) Name() string {
+	return idPluginName
+}
+
+func (p *IDPlugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:before_create").Register(idPluginName, p.assignIDs)
+}
+
+func (p *IDPlugin) assignIDs(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || !embedsCommon(db.Statement.Schema) {
+		return
+	}
+	if p.Skip != nil && p.Skip(db.Statement.Schema) {
+		return
+	}
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		missing := []reflect.Value{}
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if _, zero := pk.ValueOf(ctx, elem); zero {
+				missing = append(missing, elem)
+			}
+		}
+		if len(missing) == 0 {
+			return
+		}
+		for i, id := range idgen.GenIds(len(missing)) {
+			if err := pk.Set(ctx, missing[i], id); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if _, zero := pk.ValueOf(ctx, rv); zero {
+			_ = db.AddError(pk.Set(ctx, rv, idgen.GenID()))
+		}
+	}
+}
+
+// embedsCommon reports whether the model of s embeds model.Common.
+func embedsCommon(s *schema.Schema) bool {
+	f, ok := s.ModelType.FieldByName("Common")
+	return ok && f.Anonymous && f.Type == reflect.TypeOf(model.Common{}) && s.PrioritizedPrimaryField != nil &&
+		s.PrioritizedPrimaryField.Name == "ID"
+}
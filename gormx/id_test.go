@@ -0,0 +1,70 @@
+package gormx
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm/schema"
+
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/idgen"
+)
+
+func TestIDPlugin(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("batch create", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		if err := db.Use(&IDPlugin{}); err != nil {
+			t.Fatalf("Use() error = %v", err)
+		}
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`")).WillReturnResult(sqlmock.NewResult(0, 3))
+
+		profiles := []*testProfile{{}, {Common: model.Common{ID: 42}}, {}}
+		if err := db.Create(profiles).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if profiles[0].ID == 0 || profiles[2].ID == 0 || profiles[0].ID == profiles[2].ID {
+			t.Errorf("Create() ids = %v, %v, want distinct IDs", profiles[0].ID, profiles[2].ID)
+		}
+		if profiles[1].ID != 42 {
+			t.Errorf("Create() id = %v, want 42", profiles[1].ID)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		plugin := &IDPlugin{Skip: func(s *schema.Schema) bool { return s.Table == "test_profiles" }}
+		if err := db.Use(plugin); err != nil {
+			t.Fatalf("Use() error = %v", err)
+		}
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p := &testProfile{}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if p.ID != 0 {
+			t.Errorf("Create() id = %v, want 0", p.ID)
+		}
+	})
+
+	t.Run("model without common", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		if err := db.Use(&IDPlugin{}); err != nil {
+			t.Fatalf("Use() error = %v", err)
+		}
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_users`")).WillReturnResult(sqlmock.NewResult(7, 1))
+
+		u := &testUser{Name: "name"}
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if u.ID != 7 {
+			t.Errorf("Create() id = %v, want auto increment 7", u.ID)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"sync"
+
+	filter "github.com/ahiho/gocandy/filter/adapter/sql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IterateRequest controls how Iterate walks through all rows matching a query.
+// Rows are read in batches ordered by primary key (keyset pagination), so the walk is stable
+// even if rows are inserted or deleted while iterating.
+type IterateRequest struct {
+	// BatchSize is the number of rows read per query. Defaults to DefaultBatchSize.
+	BatchSize int
+	Filter    string
+	Adaptor   *filter.SQLAdaptor
+	// ShowDeleted includes soft deleted rows (see model.SoftDelete).
+	ShowDeleted bool
+	// Concurrency is the number of goroutines running the transform function of IterateTransform
+	// on a batch. Results are still yielded in order. Defaults to 1.
+	Concurrency int
+}
+
+const (
+	DefaultBatchSize = 100
+)
+
+var (
+	ErrNoPrimaryKey = errors.New("model must have a single primary key")
+)
+
+// Iterate returns an iterator over all rows of T matching req.
+// Iteration stops at the first error, which is yielded together with the zero value of T.
+func Iterate[T any](ctx context.Context, db *gorm.DB, req IterateRequest) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for batch, err := range batches[T](ctx, db, req) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, i := range batch {
+				if !yield(i, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// IterateTransform works like Iterate but yields the results of fn applied to each row.
+func IterateTransform[T any, R any](ctx context.Context, db *gorm.DB, req IterateRequest, fn func(i T) (o R, e error)) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		var zero R
+		for batch, err := range batches[T](ctx, db, req) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			rs, err := transform(batch, req.Concurrency, fn)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, r := range rs {
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Each calls fn for every row of T matching req and stops at the first error.
+func Each[T any](ctx context.Context, db *gorm.DB, req IterateRequest, fn func(i T) error) error {
+	for i, err := range Iterate[T](ctx, db, req) {
+		if err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stream sends every row of T matching req to the returned row channel, which is closed when done.
+// At most one error is sent to the error channel, which is closed after the row channel.
+// Callers must either drain the row channel or cancel ctx.
+func Stream[T any](ctx context.Context, db *gorm.DB, req IterateRequest) (<-chan T, <-chan error) {
+	rows := make(chan T)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(rows)
+
+		for i, err := range Iterate[T](ctx, db, req) {
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case rows <- i:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return rows, errs
+}
+
+// batches yields the rows matching req in batches of req.BatchSize.
+func batches[T any](ctx context.Context, db *gorm.DB, req IterateRequest) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(new(T)); err != nil {
+			yield(nil, err)
+			return
+		}
+		pk := stmt.Schema.PrioritizedPrimaryField
+		if pk == nil {
+			yield(nil, ErrNoPrimaryKey)
+			return
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: pk.DBName}
+
+		batchSize := req.BatchSize
+		if batchSize < 1 {
+			batchSize = DefaultBatchSize
+		}
+
+		db = db.WithContext(ctx)
+		if req.ShowDeleted {
+			db = db.Unscoped()
+		}
+		tx, err := Filter(db, req.Filter, req.Adaptor)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		// Make tx reusable for every batch
+		tx = tx.Order(clause.OrderByColumn{Column: column}).Limit(batchSize).Session(&gorm.Session{})
+
+		var last interface{}
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			q := tx
+			if last != nil {
+				q = q.Where(clause.Gt{Column: column, Value: last})
+			}
+			rs := []T{}
+			if err := q.Find(&rs).Error; err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(rs) == 0 {
+				return
+			}
+			if !yield(rs, nil) {
+				return
+			}
+			if len(rs) < batchSize {
+				return
+			}
+			last, _ = pk.ValueOf(ctx, reflect.ValueOf(&rs[len(rs)-1]).Elem())
+		}
+	}
+}
+
+// transform applies fn to all items using up to concurrency goroutines and keeps the order of items.
+func transform[T any, R any](items []T, concurrency int, fn func(i T) (o R, e error)) ([]R, error) {
+	rs := make([]R, len(items))
+	if concurrency <= 1 {
+		for idx, i := range items {
+			o, e := fn(i)
+			if e != nil {
+				return nil, e
+			}
+			rs[idx] = o
+		}
+		return rs, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+	for idx, i := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, i T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			o, e := fn(i)
+			if e != nil {
+				once.Do(func() { firstErr = e })
+				return
+			}
+			rs[idx] = o
+		}(idx, i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return rs, nil
+}
@@ -0,0 +1,135 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectBatches(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 2")).
+		WillReturnRows(userRows(1, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` WHERE `test_users`.`id` > ? ORDER BY `test_users`.`id` LIMIT 2")).
+		WithArgs(2).
+		WillReturnRows(userRows(3, 4))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` WHERE `test_users`.`id` > ? ORDER BY `test_users`.`id` LIMIT 2")).
+		WithArgs(4).
+		WillReturnRows(userRows(5))
+}
+
+func TestIterate(t *testing.T) {
+	db, mock := newMockDB(t)
+	expectBatches(mock)
+
+	ids := []int64{}
+	for u, err := range Iterate[testUser](context.Background(), db, IterateRequest{BatchSize: 2}) {
+		if err != nil {
+			t.Fatalf("Iterate() error = %v", err)
+		}
+		ids = append(ids, u.ID)
+	}
+	if len(ids) != 5 || ids[4] != 5 {
+		t.Errorf("Iterate() ids = %v, want [1 2 3 4 5]", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIterate_break(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 2")).
+		WillReturnRows(userRows(1, 2))
+
+	for u := range Iterate[testUser](context.Background(), db, IterateRequest{BatchSize: 2}) {
+		if u.ID == 1 {
+			break
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIterate_canceled(t *testing.T) {
+	db, _ := newMockDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, err := range Iterate[testUser](ctx, db, IterateRequest{}) {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Iterate() error = %v, want %v", err, context.Canceled)
+		}
+	}
+}
+
+func TestIterateTransform(t *testing.T) {
+	db, mock := newMockDB(t)
+	expectBatches(mock)
+
+	got := []string{}
+	req := IterateRequest{BatchSize: 2, Concurrency: 4}
+	fn := func(u testUser) (string, error) { return strconv.FormatInt(u.ID, 10), nil }
+	for s, err := range IterateTransform(context.Background(), db, req, fn) {
+		if err != nil {
+			t.Fatalf("IterateTransform() error = %v", err)
+		}
+		got = append(got, s)
+	}
+	want := []string{"1", "2", "3", "4", "5"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("IterateTransform() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestIterateTransform_error(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 2")).
+		WillReturnRows(userRows(1, 2))
+
+	errTransform := errors.New("transform")
+	req := IterateRequest{BatchSize: 2, Concurrency: 2}
+	fn := func(u testUser) (int64, error) { return 0, errTransform }
+	for _, err := range IterateTransform(context.Background(), db, req, fn) {
+		if !errors.Is(err, errTransform) {
+			t.Errorf("IterateTransform() error = %v, want %v", err, errTransform)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	db, mock := newMockDB(t)
+	expectBatches(mock)
+
+	rows, errs := Stream[testUser](context.Background(), db, IterateRequest{BatchSize: 2})
+	count := 0
+	for range rows {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Stream() rows = %v, want 5", count)
+	}
+}
+
+func TestEach(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 2")).
+		WillReturnRows(userRows(1, 2))
+
+	errStop := errors.New("stop")
+	err := Each(context.Background(), db, IterateRequest{BatchSize: 2}, func(u testUser) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("Each() error = %v, want %v", err, errStop)
+	}
+}
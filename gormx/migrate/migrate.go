@@ -0,0 +1,473 @@
+// Package migrate runs versioned schema migrations on MySQL, replacing gorm AutoMigrate in production.
+//
+// Applied migrations are recorded in a table with their checksum, so changed migrations are detected.
+// Runs are serialized with MySQL GET_LOCK, several instances of a service can migrate on startup:
+//
+//	migrations, err := migrate.LoadFS(migrationFiles)
+//	m, err := migrate.New(migrations, migrate.Options{})
+//	applied, err := m.Up(ctx, db)
+//
+// Every migration runs in a transaction together with its record. MySQL commits DDL statements
+// implicitly, so DDL is not atomic: a migration failing after a DDL statement leaves a partially
+// applied schema. The migration is therefore recorded as dirty before it runs and marked clean by its
+// transaction. Up and Down refuse to run with ErrDirty past a dirty migration until the schema is
+// fixed by hand and the migration is resolved with Repair. Keep migrations with DDL to a single
+// statement where possible. Migrations which only change data are rolled back completely instead,
+// they are not left dirty (see Migration.Transactional).
+//
+// In tests, testdb.GetMigratedMysqlDB(m.Migrate) returns a database with all migrations applied.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ahiho/gocandy/xcontext"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTable       = "schema_migrations"
+	DefaultLockName    = "gocandy_migrate"
+	DefaultLockTimeout = time.Minute
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum of applied migration changed")
+	ErrOutOfOrder       = errors.New("migration is older than the last applied migration")
+	ErrUnknownMigration = errors.New("applied migration is unknown")
+	ErrLockTimeout      = errors.New("timeout acquiring migration lock")
+	ErrDirty            = errors.New("migration is dirty, repair it")
+	ErrNotDirty         = errors.New("migration is not dirty")
+)
+
+// Options configures a Migrator.
+type Options struct {
+	// Table records applied migrations. Defaults to DefaultTable.
+	Table string
+	// LockName is the name of the MySQL lock held while migrating. Defaults to DefaultLockName.
+	LockName string
+	// LockTimeout is the maximum time to wait for the lock. Defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+	// AllowOutOfOrder applies pending migrations older than the last applied migration
+	// instead of failing with ErrOutOfOrder.
+	AllowOutOfOrder bool
+	// DryRun validates and logs the migrations which would run without running them.
+	DryRun bool
+	// Logf logs the migrations run. Optional.
+	Logf func(format string, args ...interface{})
+}
+
+// AppliedMigration is the record of an applied migration.
+type AppliedMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	ApplyTime time.Time
+	// Dirty is set while the migration is applied or reverted, it stays set if that failed.
+	Dirty bool
+}
+
+// Status is the state of a migration.
+type Status struct {
+	Version int64
+	Name    string
+	// Applied reports whether the migration was applied, at ApplyTime.
+	Applied   bool
+	ApplyTime time.Time
+	// Unknown reports an applied migration which is not known to the Migrator.
+	Unknown bool
+	// Dirty reports a migration which failed to apply or revert, see Repair.
+	Dirty bool
+}
+
+// Migrator applies and reverts a set of migrations.
+type Migrator struct {
+	migrations []*Migration
+	opts       Options
+}
+
+// New returns a Migrator for migrations, which can be given in any order.
+func New(migrations []*Migration, opts Options) (*Migrator, error) {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.LockName == "" {
+		opts.LockName = DefaultLockName
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = DefaultLockTimeout
+	}
+
+	sorted := append([]*Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("%w: %v has no positive version", ErrInvalidMigration, m)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("%w: duplicate version %v", ErrInvalidMigration, m.Version)
+		}
+		if m.Up == nil && m.UpSQL == "" {
+			return nil, fmt.Errorf("%w: %v has no up migration", ErrInvalidMigration, m)
+		}
+	}
+
+	return &Migrator{
+		migrations: sorted,
+		opts:       opts,
+	}, nil
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context, db *gorm.DB) ([]*Migration, error) {
+	return m.UpTo(ctx, db, m.latest())
+}
+
+// UpTo applies the pending migrations up to and including version and returns them.
+func (m *Migrator) UpTo(ctx context.Context, db *gorm.DB, version int64) ([]*Migration, error) {
+	return m.run(ctx, db, func(conn *gorm.DB, applied map[int64]AppliedMigration) ([]*Migration, error) {
+		last := int64(0)
+		for v := range applied {
+			if v > last {
+				last = v
+			}
+		}
+
+		pending := []*Migration{}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if mig.Version < last && !m.opts.AllowOutOfOrder {
+				return nil, fmt.Errorf("%w: %v", ErrOutOfOrder, mig)
+			}
+			pending = append(pending, mig)
+		}
+
+		res := []*Migration{}
+		for _, mig := range pending {
+			if err := m.apply(conn, mig); err != nil {
+				return res, err
+			}
+			res = append(res, mig)
+		}
+		return res, nil
+	})
+}
+
+// Down reverts the last applied migration and returns it, or nil if no migration is applied.
+func (m *Migrator) Down(ctx context.Context, db *gorm.DB) (*Migration, error) {
+	var res *Migration
+	_, err := m.run(ctx, db, func(conn *gorm.DB, applied map[int64]AppliedMigration) ([]*Migration, error) {
+		last := int64(0)
+		for v := range applied {
+			if v > last {
+				last = v
+			}
+		}
+		if last == 0 {
+			return nil, nil
+		}
+
+		mig := m.find(last)
+		if mig == nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownMigration, last)
+		}
+		if err := m.revert(conn, mig); err != nil {
+			return nil, err
+		}
+		res = mig
+		return nil, nil
+	})
+	return res, err
+}
+
+// DownTo reverts the applied migrations newer than version, newest first, and returns them.
+func (m *Migrator) DownTo(ctx context.Context, db *gorm.DB, version int64) ([]*Migration, error) {
+	return m.run(ctx, db, func(conn *gorm.DB, applied map[int64]AppliedMigration) ([]*Migration, error) {
+		versions := []int64{}
+		for v := range applied {
+			if v > version {
+				versions = append(versions, v)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		revert := make([]*Migration, len(versions))
+		for i, v := range versions {
+			revert[i] = m.find(v)
+			if revert[i] == nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnknownMigration, v)
+			}
+			if !revert[i].reversible() {
+				return nil, fmt.Errorf("%w: %v", ErrIrreversible, revert[i])
+			}
+		}
+
+		res := []*Migration{}
+		for _, mig := range revert {
+			if err := m.revert(conn, mig); err != nil {
+				return res, err
+			}
+			res = append(res, mig)
+		}
+		return res, nil
+	})
+}
+
+// Status returns the state of all known and applied migrations, ordered by version.
+func (m *Migrator) Status(ctx context.Context, db *gorm.DB) ([]Status, error) {
+	db = db.WithContext(ctx)
+	applied := map[int64]AppliedMigration{}
+	if db.Migrator().HasTable(m.opts.Table) {
+		var err error
+		if applied, err = m.applied(db); err != nil {
+			return nil, err
+		}
+	}
+
+	res := []Status{}
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.ApplyTime = a.ApplyTime
+			s.Dirty = a.Dirty
+			delete(applied, mig.Version)
+		}
+		res = append(res, s)
+	}
+	for _, a := range applied {
+		res = append(res, Status{Version: a.Version, Name: a.Name, Applied: true, ApplyTime: a.ApplyTime, Unknown: true, Dirty: a.Dirty})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// Migrate applies all pending migrations to db. Its signature matches testdb.GetMigratedMysqlDB.
+func (m *Migrator) Migrate(db *gorm.DB) error {
+	_, err := m.Up(context.Background(), db)
+	return err
+}
+
+// Repair resolves the dirty migration version once its schema changes have been completed or undone
+// by hand: it is recorded as applied if applied is true, or else as not applied.
+func (m *Migrator) Repair(ctx context.Context, db *gorm.DB, version int64, applied bool) error {
+	return m.locked(ctx, db, func(conn *gorm.DB, _ map[int64]AppliedMigration) error {
+		tx := conn.Table(m.opts.Table).Where("version = ? AND dirty", version)
+		if applied {
+			tx = tx.Update("dirty", false)
+		} else {
+			tx = tx.Delete(&AppliedMigration{})
+		}
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if tx.RowsAffected == 0 {
+			return fmt.Errorf("%w: %v", ErrNotDirty, version)
+		}
+		return nil
+	})
+}
+
+type runFunc func(conn *gorm.DB, applied map[int64]AppliedMigration) ([]*Migration, error)
+
+// run calls fn with the applied migrations on a single connection holding the migration lock,
+// once the applied migrations are validated and none of them is dirty.
+func (m *Migrator) run(ctx context.Context, db *gorm.DB, fn runFunc) ([]*Migration, error) {
+	var res []*Migration
+	err := m.locked(ctx, db, func(conn *gorm.DB, applied map[int64]AppliedMigration) error {
+		if err := m.validate(applied); err != nil {
+			return err
+		}
+		var err error
+		res, err = fn(conn, applied)
+		return err
+	})
+	return res, err
+}
+
+// locked calls fn with the applied migrations on a single connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, db *gorm.DB, fn func(conn *gorm.DB, applied map[int64]AppliedMigration) error) error {
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// The connection is not a new session, make it safe to reuse
+		conn = conn.Session(&gorm.Session{})
+		if err := m.lock(conn); err != nil {
+			return err
+		}
+		defer m.unlock(conn)
+
+		if !m.opts.DryRun {
+			if err := m.createTable(conn); err != nil {
+				return err
+			}
+		}
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		return fn(conn, applied)
+	})
+}
+
+// lock acquires the named MySQL lock, which is bound to the connection of conn.
+func (m *Migrator) lock(conn *gorm.DB) error {
+	var locked sql.NullInt64
+	// GET_LOCK takes whole seconds, a timeout under a second would not wait at all
+	timeout := int((m.opts.LockTimeout + time.Second - 1) / time.Second)
+	err := conn.Raw("SELECT GET_LOCK(?, ?)", m.opts.LockName, timeout).Scan(&locked).Error
+	if err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return fmt.Errorf("%w: %v", ErrLockTimeout, m.opts.LockName)
+	}
+	return nil
+}
+
+func (m *Migrator) unlock(conn *gorm.DB) {
+	// Release the lock even if ctx is canceled, a pooled connection would keep holding it
+	_ = conn.WithContext(xcontext.Detach(conn.Statement.Context)).Exec("DO RELEASE_LOCK(?)", m.opts.LockName).Error
+}
+
+func (m *Migrator) createTable(conn *gorm.DB) error {
+	return conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`checksum` VARCHAR(64) NOT NULL DEFAULT '', "+
+		"`apply_time` DATETIME(6) NOT NULL, "+
+		"`dirty` BOOLEAN NOT NULL DEFAULT FALSE)", conn.Statement.Quote(m.opts.Table))).Error
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int64]AppliedMigration, error) {
+	records := []AppliedMigration{}
+	if m.opts.DryRun && !db.Migrator().HasTable(m.opts.Table) {
+		return map[int64]AppliedMigration{}, nil
+	}
+	if err := db.Table(m.opts.Table).Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int64]AppliedMigration, len(records))
+	for _, r := range records {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// validate checks that no applied migration is dirty or changed since it was applied.
+func (m *Migrator) validate(applied map[int64]AppliedMigration) error {
+	for _, a := range applied {
+		if a.Dirty {
+			return fmt.Errorf("%w: %d_%s", ErrDirty, a.Version, a.Name)
+		}
+	}
+	for _, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		if !ok {
+			continue
+		}
+		if sum := mig.checksum(); sum != "" && a.Checksum != "" && sum != a.Checksum {
+			return fmt.Errorf("%w: %v", ErrChecksumMismatch, mig)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(conn *gorm.DB, mig *Migration) error {
+	if m.opts.DryRun {
+		m.logf("would apply migration %v", mig)
+		return nil
+	}
+
+	start := time.Now()
+	// Recorded before the transaction, so that the record stays dirty if DDL is committed before a failure
+	err := conn.Table(m.opts.Table).Create(&AppliedMigration{
+		Version:   mig.Version,
+		Name:      mig.Name,
+		Checksum:  mig.checksum(),
+		ApplyTime: start,
+		Dirty:     true,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("apply migration %v: %w", mig, err)
+	}
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		if err := mig.up(tx); err != nil {
+			return err
+		}
+		return m.clean(tx, mig).Error
+	})
+	if err != nil {
+		if mig.upTransactional() {
+			// Nothing was applied, the migration stays pending. If removing the record fails it stays dirty.
+			_ = conn.Table(m.opts.Table).Where("version = ?", mig.Version).Delete(&AppliedMigration{}).Error
+		}
+		return fmt.Errorf("apply migration %v: %w", mig, err)
+	}
+	m.logf("applied migration %v in %v", mig, time.Since(start))
+	return nil
+}
+
+func (m *Migrator) revert(conn *gorm.DB, mig *Migration) error {
+	if !mig.reversible() {
+		return fmt.Errorf("%w: %v", ErrIrreversible, mig)
+	}
+	if m.opts.DryRun {
+		m.logf("would revert migration %v", mig)
+		return nil
+	}
+
+	start := time.Now()
+	err := conn.Table(m.opts.Table).Where("version = ?", mig.Version).Update("dirty", true).Error
+	if err != nil {
+		return fmt.Errorf("revert migration %v: %w", mig, err)
+	}
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		if err := mig.down(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.opts.Table).Where("version = ?", mig.Version).Delete(&AppliedMigration{}).Error
+	})
+	if err != nil {
+		if mig.downTransactional() {
+			// Nothing was reverted, the migration stays applied. If clearing the flag fails it stays dirty.
+			_ = conn.Table(m.opts.Table).Where("version = ?", mig.Version).Update("dirty", false).Error
+		}
+		return fmt.Errorf("revert migration %v: %w", mig, err)
+	}
+	m.logf("reverted migration %v in %v", mig, time.Since(start))
+	return nil
+}
+
+// clean marks the applied migration mig as not dirty.
+func (m *Migrator) clean(tx *gorm.DB, mig *Migration) *gorm.DB {
+	return tx.Table(m.opts.Table).Where("version = ?", mig.Version).
+		Updates(map[string]interface{}{"dirty": false, "apply_time": time.Now()})
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) logf(format string, args ...interface{}) {
+	if m.opts.Logf != nil {
+		m.opts.Logf(format, args...)
+	}
+}
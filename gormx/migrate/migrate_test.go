@@ -0,0 +1,370 @@
+package migrate
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	createUsers = &Migration{
+		Version: 1,
+		Name:    "create_users",
+		UpSQL:   "CREATE TABLE users (id BIGINT PRIMARY KEY);\nCREATE INDEX idx_users_id ON users (id);",
+		DownSQL: "DROP TABLE users;",
+	}
+	addName = &Migration{
+		Version: 2,
+		Name:    "add_name",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE users ADD name VARCHAR(255)").Error
+		},
+	}
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return db, mock
+}
+
+// expectLocked expects the migration lock and table setup, returning applied as the applied migrations.
+func expectLocked(mock sqlmock.Sqlmock, applied ...*Migration) {
+	expectLockedDirty(mock, 0, applied...)
+}
+
+// expectLockedDirty is expectLocked with the applied migration dirty marked as dirty.
+func expectLockedDirty(mock sqlmock.Sqlmock, dirty int64, applied ...*Migration) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).WithArgs(DefaultLockName, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS `schema_migrations`")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlmock.NewRows([]string{"version", "name", "checksum", "apply_time", "dirty"})
+	for _, m := range applied {
+		rows.AddRow(m.Version, m.Name, m.checksum(), time.Now(), m.Version == dirty)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `schema_migrations` ORDER BY version")).WillReturnRows(rows)
+}
+
+// expectApplied expects the dirty record and the transaction of a migration running stmts.
+func expectApplied(mock sqlmock.Sqlmock, m *Migration, stmts ...string) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `schema_migrations` (`version`,`name`,`checksum`,`apply_time`,`dirty`) VALUES (?,?,?,?,?)")).
+		WithArgs(m.Version, m.Name, m.checksum(), sqlmock.AnyArg(), true).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	for _, stmt := range stmts {
+		mock.ExpectExec(regexp.QuoteMeta(stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `schema_migrations` SET `apply_time`=?,`dirty`=? WHERE version = ?")).
+		WithArgs(sqlmock.AnyArg(), false, m.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectUnlocked(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("DO RELEASE_LOCK(?)")).WithArgs(DefaultLockName).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrator_Up(t *testing.T) {
+	t.Run("apply pending", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock)
+		expectApplied(mock, createUsers, "CREATE TABLE users (id BIGINT PRIMARY KEY)", "CREATE INDEX idx_users_id ON users (id)")
+		expectApplied(mock, addName, "ALTER TABLE users ADD name VARCHAR(255)")
+		expectUnlocked(mock)
+
+		m, err := New([]*Migration{addName, createUsers}, Options{})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		applied, err := m.Up(context.Background(), db)
+		if err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+		if len(applied) != 2 || applied[0] != createUsers || applied[1] != addName {
+			t.Errorf("Up() = %v, want [%v %v]", applied, createUsers, addName)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("failed migration", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, createUsers)
+		// The record is left dirty
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `schema_migrations`")).
+			WithArgs(2, "add_name", "", sqlmock.AnyArg(), true).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("ALTER TABLE users").WillReturnError(errors.New("duplicate column"))
+		mock.ExpectRollback()
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		applied, err := m.Up(context.Background(), db)
+		if err == nil || len(applied) != 0 {
+			t.Errorf("Up() = %v, %v, want error", applied, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("failed data migration", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, createUsers)
+		// The transaction rolled everything back, the record is removed instead of left dirty
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `schema_migrations`")).
+			WithArgs(2, "seed_users", sqlmock.AnyArg(), sqlmock.AnyArg(), true).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO users").WillReturnError(errors.New("duplicate entry"))
+		mock.ExpectRollback()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `schema_migrations` WHERE version = ?")).WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUnlocked(mock)
+
+		seedUsers := &Migration{Version: 2, Name: "seed_users", UpSQL: "INSERT INTO users (id) VALUES (1);"}
+		m, _ := New([]*Migration{createUsers, seedUsers}, Options{})
+		applied, err := m.Up(context.Background(), db)
+		if err == nil || len(applied) != 0 {
+			t.Errorf("Up() = %v, %v, want error", applied, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLockedDirty(mock, 1, createUsers)
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		if _, err := m.Up(context.Background(), db); !errors.Is(err, ErrDirty) {
+			t.Errorf("Up() error = %v, want %v", err, ErrDirty)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, &Migration{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INT);"})
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		if _, err := m.Up(context.Background(), db); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Up() error = %v, want %v", err, ErrChecksumMismatch)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, addName)
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		if _, err := m.Up(context.Background(), db); !errors.Is(err, ErrOutOfOrder) {
+			t.Errorf("Up() error = %v, want %v", err, ErrOutOfOrder)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT DATABASE()")).WillReturnRows(sqlmock.NewRows([]string{"db"}).AddRow("test"))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM information_schema.tables")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUnlocked(mock)
+
+		logged := []string{}
+		m, _ := New([]*Migration{createUsers, addName}, Options{
+			DryRun: true,
+			Logf: func(format string, args ...interface{}) {
+				logged = append(logged, format)
+			},
+		})
+		applied, err := m.Up(context.Background(), db)
+		if err != nil || len(applied) != 2 {
+			t.Errorf("Up() = %v, %v, want 2 migrations", applied, err)
+		}
+		if len(logged) != 2 {
+			t.Errorf("Up() logged %v, want 2 lines", logged)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("lock timeout", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).WithArgs("app", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+
+		m, _ := New([]*Migration{createUsers}, Options{LockName: "app", LockTimeout: time.Second})
+		if _, err := m.Up(context.Background(), db); !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("Up() error = %v, want %v", err, ErrLockTimeout)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("lock timeout under a second", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).WithArgs("app", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+
+		m, _ := New([]*Migration{createUsers}, Options{LockName: "app", LockTimeout: 100 * time.Millisecond})
+		if _, err := m.Up(context.Background(), db); !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("Up() error = %v, want %v", err, ErrLockTimeout)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	t.Run("revert", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, createUsers)
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE `schema_migrations` SET `dirty`=? WHERE version = ?")).WithArgs(true, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("DROP TABLE users")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `schema_migrations` WHERE version = ?")).WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		reverted, err := m.Down(context.Background(), db)
+		if err != nil || reverted != createUsers {
+			t.Errorf("Down() = %v, %v, want %v", reverted, err, createUsers)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("failed transactional migration", func(t *testing.T) {
+		backfill := &Migration{
+			Version:       2,
+			Name:          "backfill_names",
+			Up:            func(tx *gorm.DB) error { return tx.Exec("UPDATE users SET name = 'a'").Error },
+			Down:          func(tx *gorm.DB) error { return tx.Exec("UPDATE users SET name = NULL").Error },
+			Transactional: true,
+		}
+		db, mock := newMockDB(t)
+		expectLocked(mock, createUsers, backfill)
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE `schema_migrations` SET `dirty`=? WHERE version = ?")).WithArgs(true, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET name = NULL")).WillReturnError(errors.New("lock wait timeout"))
+		mock.ExpectRollback()
+		// Still applied, the dirty flag is cleared
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE `schema_migrations` SET `dirty`=? WHERE version = ?")).WithArgs(false, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, backfill}, Options{})
+		if _, err := m.Down(context.Background(), db); err == nil {
+			t.Error("Down() error = nil, want error")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("irreversible", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectLocked(mock, createUsers, addName)
+		expectUnlocked(mock)
+
+		m, _ := New([]*Migration{createUsers, addName}, Options{})
+		if _, err := m.DownTo(context.Background(), db, 0); !errors.Is(err, ErrIrreversible) {
+			t.Errorf("DownTo() error = %v, want %v", err, ErrIrreversible)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestMigrator_Repair(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied bool
+		query   string
+		args    []driver.Value
+		rows    int64
+		wantErr error
+	}{
+		{"applied", true, "UPDATE `schema_migrations` SET `dirty`=? WHERE version = ? AND dirty", []driver.Value{false, 2}, 1, nil},
+		{"not applied", false, "DELETE FROM `schema_migrations` WHERE version = ? AND dirty", []driver.Value{2}, 1, nil},
+		{"not dirty", true, "UPDATE `schema_migrations` SET `dirty`=? WHERE version = ? AND dirty", []driver.Value{false, 2}, 0, ErrNotDirty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			expectLockedDirty(mock, 2, createUsers, addName)
+			mock.ExpectExec(regexp.QuoteMeta(tt.query)).WithArgs(tt.args...).WillReturnResult(sqlmock.NewResult(0, tt.rows))
+			expectUnlocked(mock)
+
+			m, _ := New([]*Migration{createUsers, addName}, Options{})
+			if err := m.Repair(context.Background(), db, 2, tt.applied); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Repair() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []*Migration
+	}{
+		{"duplicate version", []*Migration{createUsers, {Version: 1, Name: "other", UpSQL: "SELECT 1"}}},
+		{"no version", []*Migration{{Name: "create_users", UpSQL: "SELECT 1"}}},
+		{"no up", []*Migration{{Version: 3, Name: "empty"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.migrations, Options{}); !errors.Is(err, ErrInvalidMigration) {
+				t.Errorf("New() error = %v, want %v", err, ErrInvalidMigration)
+			}
+		})
+	}
+}
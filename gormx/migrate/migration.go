@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrIrreversible     = errors.New("migration is irreversible")
+)
+
+// sqlFile matches SQL migration file names like 0001_create_users.up.sql.
+var sqlFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change, written in Go or SQL.
+type Migration struct {
+	// Version orders migrations, it must be positive and unique.
+	Version int64
+	Name    string
+
+	// Up and Down apply and revert a Go migration. Down is optional.
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+
+	// UpSQL and DownSQL apply and revert a SQL migration. DownSQL is optional.
+	// Statements are separated by a semicolon at the end of a line.
+	UpSQL   string
+	DownSQL string
+
+	// Checksum detects changes to applied migrations. It defaults to the SHA-256 of UpSQL,
+	// Go migrations are not checked unless it is set.
+	Checksum string
+
+	// Transactional declares that the migration only changes data, without DDL statements, so a failure
+	// rolls it back completely and it is not left dirty. SQL migrations are transactional when all
+	// their statements are INSERT, UPDATE, DELETE or REPLACE statements.
+	Transactional bool
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+func (m *Migration) checksum() string {
+	if m.Checksum != "" || m.UpSQL == "" {
+		return m.Checksum
+	}
+	sum := sha256.Sum256([]byte(m.UpSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return execSQL(tx, m.UpSQL)
+}
+
+func (m *Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if m.DownSQL != "" {
+		return execSQL(tx, m.DownSQL)
+	}
+	return fmt.Errorf("%w: %v", ErrIrreversible, m)
+}
+
+// upTransactional reports whether a failure of the up migration rolls it back completely.
+func (m *Migration) upTransactional() bool {
+	return m.Transactional || (m.Up == nil && dataOnly(m.UpSQL))
+}
+
+// downTransactional reports whether a failure of the down migration rolls it back completely.
+func (m *Migration) downTransactional() bool {
+	return m.Transactional || (m.Down == nil && dataOnly(m.DownSQL))
+}
+
+// dataOnly reports whether sql only has statements changing data, which MySQL does not commit implicitly.
+func dataOnly(sql string) bool {
+	stmts := splitStatements(sql)
+	for _, stmt := range stmts {
+		switch strings.ToUpper(strings.Fields(stmt)[0]) {
+		case "INSERT", "UPDATE", "DELETE", "REPLACE":
+		default:
+			return false
+		}
+	}
+	return len(stmts) > 0
+}
+
+func (m *Migration) reversible() bool {
+	return m.Down != nil || m.DownSQL != ""
+}
+
+// LoadFS returns the SQL migrations in the root of fsys, usually an embed.FS (use fs.Sub for a subdirectory).
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql, other files are ignored.
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+func LoadFS(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	res := []*Migration{}
+	for _, e := range entries {
+		match := sqlFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrInvalidMigration, e.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+			res = append(res, m)
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: %v has different names", ErrInvalidMigration, version)
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	for _, m := range res {
+		if strings.TrimSpace(m.UpSQL) == "" {
+			return nil, fmt.Errorf("%w: %v has no up migration", ErrInvalidMigration, m)
+		}
+	}
+	return res, nil
+}
+
+// execSQL executes the statements of sql one by one, as the MySQL driver rejects
+// multiple statements by default.
+func execSQL(tx *gorm.DB, sql string) error {
+	for _, stmt := range splitStatements(sql) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits sql at semicolons ending a line.
+func splitStatements(sql string) []string {
+	res := []string{}
+	current := strings.Builder{}
+	flush := func() {
+		stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+		if stmt != "" {
+			res = append(res, stmt)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+	return res
+}
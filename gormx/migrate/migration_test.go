@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadFS(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email VARCHAR(255);")},
+			"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+			"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+			"README.md":                  {Data: []byte("migrations")},
+		}
+		got, err := LoadFS(fsys)
+		if err != nil {
+			t.Fatalf("LoadFS() error = %v", err)
+		}
+		want := []*Migration{
+			{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id BIGINT);", DownSQL: "DROP TABLE users;"},
+			{Version: 2, Name: "add_email", UpSQL: "ALTER TABLE users ADD email VARCHAR(255);"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadFS() = %v, want %v", got, want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"missing up", fstest.MapFS{"0001_users.down.sql": {Data: []byte("DROP TABLE users;")}}},
+		{"different names", fstest.MapFS{
+			"0001_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+			"0001_people.up.sql":  {Data: []byte("CREATE TABLE people (id BIGINT);")},
+			"0001_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadFS(tt.fsys); !errors.Is(err, ErrInvalidMigration) {
+				t.Errorf("LoadFS() error = %v, want %v", err, ErrInvalidMigration)
+			}
+		})
+	}
+}
+
+func Test_splitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "single",
+			sql:  "CREATE TABLE users (id BIGINT);\n",
+			want: []string{"CREATE TABLE users (id BIGINT)"},
+		},
+		{
+			name: "multiple lines",
+			sql: `-- users
+CREATE TABLE users (
+	id BIGINT,
+	name VARCHAR(255)
+);
+
+CREATE INDEX idx_users_name ON users (name);
+INSERT INTO users VALUES (1, 'a;b')`,
+			want: []string{
+				"CREATE TABLE users (\n\tid BIGINT,\n\tname VARCHAR(255)\n)",
+				"CREATE INDEX idx_users_name ON users (name)",
+				"INSERT INTO users VALUES (1, 'a;b')",
+			},
+		},
+		{
+			name: "empty",
+			sql:  "\n-- nothing\n",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dataOnly(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"INSERT INTO roles VALUES (1, 'admin');\nupdate users SET role = 1;", true},
+		{"DELETE FROM sessions;\nALTER TABLE sessions DROP token;", false},
+		{"CREATE TABLE users (id BIGINT);", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := dataOnly(tt.sql); got != tt.want {
+			t.Errorf("dataOnly(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package mysqltest runs the tests of gormx which need a MySQL database from testdb. It is a separate
+// module, so that gormx does not depend on testdb and dockertest. The tests are skipped if docker is
+// not available.
+package mysqltest
@@ -0,0 +1,59 @@
+module github.com/ahiho/gocandy/gormx/mysqltest
+
+go 1.23
+
+require (
+	github.com/ahiho/gocandy/gormx v0.0.0-20261019111419-0b2dc4f9466a
+	github.com/ahiho/gocandy/idgen v0.0.0-20261019084858-64c64debd35b
+	github.com/ahiho/gocandy/testdb v0.0.0-20261019100950-2dd53536ca54
+	gorm.io/gorm v1.23.10
+)
+
+require (
+	github.com/Azure/go-ansiterm v0.0.0-20170929234023-d6e3b3328b78 // indirect
+	github.com/Microsoft/go-winio v0.5.2 // indirect
+	github.com/Nvveen/Gotty v0.0.0-20120604004816-cd527374f1e5 // indirect
+	github.com/ahiho/gocandy/apperror v0.0.0-20261019111112-094aabd0d98a // indirect
+	github.com/ahiho/gocandy/fieldmask v0.0.0-20261019100950-41d50864a1d0 // indirect
+	github.com/ahiho/gocandy/filter v0.0.0-20261019100950-41d50864a1d0 // indirect
+	github.com/ahiho/gocandy/gormx/model v0.0.0-20220627170231-e8e96d96368c // indirect
+	github.com/ahiho/gocandy/listing v0.0.0-20261019100950-41d50864a1d0 // indirect
+	github.com/ahiho/gocandy/resource v0.0.0-20220627063417-90993ef64e79 // indirect
+	github.com/ahiho/gocandy/xcontext v0.0.0-20261019084858-64c64debd35b // indirect
+	github.com/bwmarrin/snowflake v0.3.1-0.20221123153919-bc74ab286f15 // indirect
+	github.com/cenkalti/backoff/v4 v4.1.3 // indirect
+	github.com/containerd/continuity v0.3.0 // indirect
+	github.com/docker/cli v20.10.14+incompatible // indirect
+	github.com/docker/docker v20.10.7+incompatible // indirect
+	github.com/docker/go-connections v0.4.0 // indirect
+	github.com/docker/go-units v0.4.0 // indirect
+	github.com/go-sql-driver/mysql v1.6.0 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.11.3 // indirect
+	github.com/iancoleman/strcase v0.2.0 // indirect
+	github.com/imdario/mergo v0.3.12 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/jolestar/go-commons-pool/v2 v2.1.2 // indirect
+	github.com/mitchellh/mapstructure v1.4.1 // indirect
+	github.com/moby/term v0.0.0-20201216013528-df9cb8a40635 // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/opencontainers/image-spec v1.0.2 // indirect
+	github.com/opencontainers/runc v1.1.2 // indirect
+	github.com/ory/dockertest/v3 v3.9.1 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/sirupsen/logrus v1.8.1 // indirect
+	github.com/xeipuuv/gojsonpointer v0.0.0-20180127040702-4e3ac2762d5f // indirect
+	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
+	github.com/xeipuuv/gojsonschema v1.2.0 // indirect
+	golang.org/x/net v0.0.0-20220706163947-c90051bbdb60 // indirect
+	golang.org/x/sys v0.0.0-20220610221304-9f5ed59c137d // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
+	google.golang.org/grpc v1.49.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gorm.io/driver/mysql v1.3.6 // indirect
+)
@@ -0,0 +1,95 @@
+package mysqltest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ahiho/gocandy/gormx/migrate"
+	"github.com/ahiho/gocandy/testdb"
+	"gorm.io/gorm"
+)
+
+var (
+	createUsers = &migrate.Migration{
+		Version: 1,
+		Name:    "create_users",
+		UpSQL:   "CREATE TABLE users (id BIGINT PRIMARY KEY);\nCREATE INDEX idx_users_id ON users (id);",
+		DownSQL: "DROP TABLE users;",
+	}
+	addName = &migrate.Migration{
+		Version: 2,
+		Name:    "add_name",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE users ADD name VARCHAR(255)").Error
+		},
+	}
+)
+
+func TestMigrator_MySQL(t *testing.T) {
+	m, err := migrate.New([]*migrate.Migration{createUsers, addName}, migrate.Options{})
+	if err != nil {
+		t.Fatalf("migrate.New() error = %v", err)
+	}
+	db, err := testdb.GetMigratedMysqlDB(m.Migrate)
+	if err != nil {
+		t.Skipf("testdb.GetMigratedMysqlDB() error = %v", err)
+	}
+	defer testdb.ReleaseMysqlDB(db)
+
+	ctx := context.Background()
+	status, err := m.Status(ctx, db)
+	if err != nil || len(status) != 2 || !status[0].Applied || !status[1].Applied {
+		t.Fatalf("Status() = %+v, %v, want all applied", status, err)
+	}
+	if !db.Migrator().HasColumn("users", "name") {
+		t.Error("column users.name does not exist")
+	}
+	if applied, err := m.Up(ctx, db); err != nil || len(applied) != 0 {
+		t.Errorf("Up() = %v, %v, want no migrations", applied, err)
+	}
+	if _, err := m.DownTo(ctx, db, 0); !errors.Is(err, migrate.ErrIrreversible) {
+		t.Errorf("DownTo() error = %v, want %v", err, migrate.ErrIrreversible)
+	}
+}
+
+func TestMigrator_MySQLDirty(t *testing.T) {
+	addEmail := &migrate.Migration{
+		Version: 3,
+		Name:    "add_email",
+		// The first statement is committed before the second fails
+		UpSQL: "ALTER TABLE users ADD email VARCHAR(255);\nALTER TABLE users ADD email VARCHAR(255);",
+	}
+	m, err := migrate.New([]*migrate.Migration{createUsers, addName}, migrate.Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	db, err := testdb.GetMigratedMysqlDB(m.Migrate)
+	if err != nil {
+		t.Skipf("testdb.GetMigratedMysqlDB() error = %v", err)
+	}
+	defer testdb.ReleaseMysqlDB(db)
+
+	ctx := context.Background()
+	m, _ = migrate.New([]*migrate.Migration{createUsers, addName, addEmail}, migrate.Options{})
+	if _, err := m.Up(ctx, db); err == nil {
+		t.Fatal("Up() error = nil, want error")
+	}
+	status, err := m.Status(ctx, db)
+	if err != nil || len(status) != 3 || !status[2].Dirty {
+		t.Fatalf("Status() = %+v, %v, want %v dirty", status, err, addEmail)
+	}
+	if _, err := m.Up(ctx, db); !errors.Is(err, migrate.ErrDirty) {
+		t.Errorf("Up() error = %v, want %v", err, migrate.ErrDirty)
+	}
+
+	if err := m.Repair(ctx, db, addEmail.Version, true); err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+	if applied, err := m.Up(ctx, db); err != nil || len(applied) != 0 {
+		t.Errorf("Up() = %v, %v, want no migrations", applied, err)
+	}
+	if err := m.Repair(ctx, db, addEmail.Version, true); !errors.Is(err, migrate.ErrNotDirty) {
+		t.Errorf("Repair() error = %v, want %v", err, migrate.ErrNotDirty)
+	}
+}
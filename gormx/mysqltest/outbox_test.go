@@ -0,0 +1,83 @@
+package mysqltest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ahiho/gocandy/gormx"
+	"github.com/ahiho/gocandy/gormx/outbox"
+	"github.com/ahiho/gocandy/idgen"
+	"github.com/ahiho/gocandy/testdb"
+	"gorm.io/gorm"
+)
+
+// newMysqlDB returns a migrated MySQL database from testdb, the test is skipped if docker is not available.
+func newMysqlDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := testdb.GetMysqlDB()
+	if err != nil {
+		t.Skipf("testdb.GetMysqlDB() error = %v", err)
+	}
+	t.Cleanup(func() { testdb.ReleaseMysqlDB(db) })
+
+	if err := db.AutoMigrate(&outbox.Record{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return db
+}
+
+func TestOutbox_MySQL(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+	db := newMysqlDB(t)
+	ctx := context.Background()
+	txm := gormx.NewTxManager(db, gormx.TxOptions{})
+
+	rollback := errors.New("rollback")
+	err := txm.Do(ctx, func(ctx context.Context) error {
+		if err := outbox.Write(ctx, db, outbox.Event{Topic: "discarded"}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Do() error = %v, want %v", err, rollback)
+	}
+	err = txm.Do(ctx, func(ctx context.Context) error {
+		return outbox.Write(ctx, db, outbox.Event{Topic: "first", Key: "1"}, outbox.Event{Topic: "second", Key: "1"}, outbox.Event{Topic: "third", Key: "1"})
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	failed := false
+	pub := &outbox.MemoryPublisher{Fail: func(r *outbox.Record) error {
+		if r.Topic == "second" && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}}
+	relay := outbox.NewRelay(db, pub, outbox.RelayOptions{Backoff: 200 * time.Millisecond})
+
+	if n, err := relay.Process(ctx); err != nil || n != 1 {
+		t.Fatalf("Process() = %v, %v, want 1", n, err)
+	}
+	// third is held back until second is published
+	if n, err := relay.Process(ctx); err != nil || n != 0 {
+		t.Fatalf("Process() = %v, %v, want 0", n, err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if n, err := relay.Process(ctx); err != nil || n != 2 {
+		t.Fatalf("Process() = %v, %v, want 2", n, err)
+	}
+
+	records := pub.Records()
+	if len(records) != 3 || records[0].Topic != "first" || records[1].Topic != "second" || records[2].Topic != "third" ||
+		records[1].Attempts != 1 {
+		t.Errorf("Records() = %+v, want first, second and third", records)
+	}
+}
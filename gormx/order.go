@@ -0,0 +1,69 @@
+package gormx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrInvalidOrderBy = errors.New("invalid order by")
+)
+
+// OrderBy parses an AIP-132 ordering clause such as "name desc, create_time" against the schema s.
+// Only fields known to the schema are accepted. The primary key is appended as a tiebreaker so that
+// the resulting order is stable across pages.
+func OrderBy(s *schema.Schema, orderBy string) ([]clause.OrderByColumn, error) {
+	columns := []clause.OrderByColumn{}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(orderBy, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			if strings.TrimSpace(orderBy) == "" {
+				break
+			}
+			return nil, fmt.Errorf("%w: empty field in %q", ErrInvalidOrderBy, orderBy)
+		}
+		if len(words) > 2 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidOrderBy, part)
+		}
+
+		field := s.LookUpField(words[0])
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidOrderBy, words[0])
+		}
+		desc := false
+		if len(words) == 2 {
+			switch strings.ToLower(words[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, fmt.Errorf("%w: unknown direction %q", ErrInvalidOrderBy, words[1])
+			}
+		}
+		if seen[field.DBName] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidOrderBy, words[0])
+		}
+		seen[field.DBName] = true
+
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Desc:   desc,
+		})
+	}
+
+	for _, pk := range s.PrimaryFields {
+		if !seen[pk.DBName] {
+			columns = append(columns, clause.OrderByColumn{
+				Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName},
+			})
+		}
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,77 @@
+package gormx
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func TestOrderBy(t *testing.T) {
+	s, err := schema.Parse(&testArticle{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("schema.Parse() error = %v", err)
+	}
+	column := func(name string, desc bool) clause.OrderByColumn {
+		return clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: name}, Desc: desc}
+	}
+
+	tests := []struct {
+		name    string
+		orderBy string
+		want    []clause.OrderByColumn
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			want: []clause.OrderByColumn{column("id", false)},
+		},
+		{
+			name:    "multiple fields",
+			orderBy: "title desc, create_time",
+			want:    []clause.OrderByColumn{column("title", true), column("create_time", false), column("id", false)},
+		},
+		{
+			name:    "primary key",
+			orderBy: "id DESC",
+			want:    []clause.OrderByColumn{column("id", true)},
+		},
+		{
+			name:    "unknown field",
+			orderBy: "title; DROP TABLE users",
+			wantErr: true,
+		},
+		{
+			name:    "unknown direction",
+			orderBy: "title up",
+			wantErr: true,
+		},
+		{
+			name:    "empty field",
+			orderBy: "title,",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate field",
+			orderBy: "title, title desc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OrderBy(s, tt.orderBy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OrderBy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOrderBy) {
+				t.Errorf("OrderBy() error = %v, want %v", err, ErrInvalidOrderBy)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrderBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher is a Publisher keeping published records in memory, for tests.
+type MemoryPublisher struct {
+	// Fail is called before a record is published, a returned error fails the publish. Optional.
+	Fail func(r *Record) error
+
+	mu      sync.Mutex
+	records []Record
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, r *Record) error {
+	if p.Fail != nil {
+		if err := p.Fail(r); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, *r)
+	return nil
+}
+
+// Records returns the published records in the order they were published.
+func (p *MemoryPublisher) Records() []Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Record(nil), p.records...)
+}
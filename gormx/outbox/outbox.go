@@ -0,0 +1,91 @@
+// Package outbox implements the transactional outbox pattern on top of gorm.
+//
+// Events are written to the outbox table in the same transaction as the business change,
+// a Relay polls the table and hands pending records to a Publisher:
+//
+//	err := txm.Do(ctx, func(ctx context.Context) error {
+//		if err := users.Create(ctx, u); err != nil {
+//			return err
+//		}
+//		return outbox.Write(ctx, db, outbox.Event{Topic: "user.created", Key: id, Payload: payload})
+//	})
+//
+// Delivery is at least once, consumers must be idempotent. The record ID can be used for deduplication.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ahiho/gocandy/gormx"
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/idgen"
+	"gorm.io/gorm"
+)
+
+const (
+	// TableName is the table of outbox records.
+	TableName = "outbox_records"
+)
+
+var (
+	ErrNoTopic = errors.New("outbox event has no topic")
+)
+
+// Record is a stored outbox event. Migrate it with db.AutoMigrate(&outbox.Record{}).
+type Record struct {
+	model.Common
+	Topic   string            `gorm:"size:255;not null"`
+	Key     string            `gorm:"size:255;index"`
+	Payload []byte            `gorm:"type:LONGBLOB"`
+	Headers map[string]string `gorm:"serializer:json;type:TEXT"`
+	// Attempts is the number of failed publish attempts.
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"type:TEXT"`
+	// NextAttemptTime is the earliest time the record is published.
+	NextAttemptTime time.Time `gorm:"index:idx_outbox_records_pending,priority:2;type:DATETIME(6)"`
+	// DeliverTime is the time the record was published, nil while it is pending.
+	DeliverTime *time.Time `gorm:"index:idx_outbox_records_pending,priority:1;type:DATETIME(6)"`
+}
+
+func (Record) TableName() string {
+	return TableName
+}
+
+// Event is an event to write to the outbox.
+type Event struct {
+	Topic string
+	// Key is passed to the Publisher, e.g. as message key for partitioning. The events of a key are
+	// published in the order they were written. Optional.
+	Key     string
+	Payload []byte
+	Headers map[string]string
+}
+
+// Write stores events in the outbox using the transaction carried by ctx (see gormx.TxManager),
+// so they are only published if the transaction commits. Without a transaction, events are written
+// on their own. Records get snowflake IDs from idgen.
+func Write(ctx context.Context, db *gorm.DB, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	ids := idgen.GenIds(len(events))
+	records := make([]*Record, len(events))
+	for i, e := range events {
+		if e.Topic == "" {
+			return ErrNoTopic
+		}
+		records[i] = &Record{
+			Common:          model.Common{ID: ids[i]},
+			Topic:           e.Topic,
+			Key:             e.Key,
+			Payload:         e.Payload,
+			Headers:         e.Headers,
+			NextAttemptTime: now,
+		}
+	}
+	return gormx.DBFromContext(ctx, db).Create(&records).Error
+}
@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahiho/gocandy/idgen"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return db, mock
+}
+
+func TestWrite(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("insert", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectExec("INSERT INTO `outbox_records`").
+			WithArgs(
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "user.created", "1", []byte("a"), `{"v":"1"}`, 0, "", sqlmock.AnyArg(), nil,
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "user.updated", "1", []byte("b"), "null", 0, "", sqlmock.AnyArg(), nil,
+			).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		err := Write(context.Background(), db,
+			Event{Topic: "user.created", Key: "1", Payload: []byte("a"), Headers: map[string]string{"v": "1"}},
+			Event{Topic: "user.updated", Key: "1", Payload: []byte("b")},
+		)
+		if err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("no topic", func(t *testing.T) {
+		db, _ := newMockDB(t)
+		if err := Write(context.Background(), db, Event{Payload: []byte("a")}); !errors.Is(err, ErrNoTopic) {
+			t.Errorf("Write() error = %v, want %v", err, ErrNoTopic)
+		}
+	})
+}
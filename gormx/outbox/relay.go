@@ -0,0 +1,213 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultBackoff      = time.Second
+	DefaultMaxBackoff   = 5 * time.Minute
+)
+
+// Publisher publishes outbox records to a message broker.
+type Publisher interface {
+	// Publish publishes r. A returned error schedules a retry of r.
+	Publish(ctx context.Context, r *Record) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, r *Record) error
+
+func (f PublisherFunc) Publish(ctx context.Context, r *Record) error {
+	return f(ctx, r)
+}
+
+// RelayOptions configures a Relay.
+type RelayOptions struct {
+	// BatchSize is the maximum number of records published per transaction. Defaults to DefaultBatchSize.
+	BatchSize int
+	// PollInterval is the delay between polls when the outbox is drained. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Backoff is the delay before retrying a failed record, it doubles with every failed attempt
+	// up to MaxBackoff. Defaults to DefaultBackoff and DefaultMaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of failed attempts after which a record is given up.
+	// Given up records stay in the outbox. Optional, records are retried forever by default.
+	MaxAttempts int
+	// OnError is called with errors of Run which are not publish errors.
+	// Defaults to logging them with the standard logger.
+	OnError func(err error)
+}
+
+// Relay publishes pending outbox records.
+//
+// Records with the same Key are published in the order they were written: when a record fails to
+// publish, the later records of its key wait until it is published or given up. Records without Key
+// are not ordered.
+//
+// Records are locked with SELECT ... FOR UPDATE SKIP LOCKED while they are published, so several
+// relays can run concurrently (MySQL 8.0 or later is required). Concurrent relays may publish records
+// of the same key out of order, run a single relay where the order matters. If a transaction fails
+// after records were published they are published again.
+type Relay struct {
+	db   *gorm.DB
+	pub  Publisher
+	opts RelayOptions
+	wake chan struct{}
+}
+
+// NewRelay returns a Relay publishing the records stored in db with pub.
+func NewRelay(db *gorm.DB, pub Publisher, opts RelayOptions) *Relay {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("outbox relay: %v", err)
+		}
+	}
+	return &Relay{
+		db:   db,
+		pub:  pub,
+		opts: opts,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Run publishes pending records until ctx is done and returns ctx.Err().
+func (r *Relay) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		case <-r.wake:
+		}
+
+		n, err := r.Process(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.opts.OnError(err)
+		}
+		if n == r.opts.BatchSize {
+			// More records are probably pending
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.opts.PollInterval)
+		}
+	}
+}
+
+// Notify makes Run poll immediately instead of waiting for the poll interval, e.g. from a hook:
+//
+//	gormx.AfterCommit(ctx, func(context.Context) { relay.Notify() })
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Process publishes one batch of pending records in the order they were written and returns
+// the number of records published. Records failing to publish are scheduled for a retry, the later
+// records of their key are held back.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	published := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deliver_time IS NULL AND next_attempt_time <= ?", now)
+		if r.opts.MaxAttempts > 0 {
+			query = query.Where("attempts < ?", r.opts.MaxAttempts)
+		}
+		query = query.Where(r.heldBack(now))
+		records := []*Record{}
+		if err := query.Order("id").Limit(r.opts.BatchSize).Find(&records).Error; err != nil {
+			return err
+		}
+
+		delivered := []int64{}
+		failedKeys := map[string]bool{}
+		for _, rec := range records {
+			if failedKeys[rec.Key] {
+				continue
+			}
+			if err := r.pub.Publish(ctx, rec); err != nil {
+				if rec.Key != "" {
+					failedKeys[rec.Key] = true
+				}
+				err := tx.Model(rec).Updates(map[string]interface{}{
+					"attempts":          rec.Attempts + 1,
+					"last_error":        err.Error(),
+					"next_attempt_time": now.Add(r.backoff(rec.Attempts + 1)),
+				}).Error
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			delivered = append(delivered, rec.ID)
+		}
+		if len(delivered) == 0 {
+			return nil
+		}
+
+		err := tx.Model(&Record{}).Where("id IN ?", delivered).Update("deliver_time", now).Error
+		if err == nil {
+			published = len(delivered)
+		}
+		return err
+	})
+	return published, err
+}
+
+// heldBack returns the condition leaving out the records with an earlier record of the same key
+// waiting for a retry.
+func (r *Relay) heldBack(now time.Time) clause.Expression {
+	sql := "`key` = '' OR NOT EXISTS (SELECT 1 FROM " + TableName + " AS earlier" +
+		" WHERE earlier.`key` = " + TableName + ".`key` AND earlier.id < " + TableName + ".id" +
+		" AND earlier.deliver_time IS NULL AND earlier.next_attempt_time > ?"
+	vars := []interface{}{now}
+	if r.opts.MaxAttempts > 0 {
+		sql += " AND earlier.attempts < ?"
+		vars = append(vars, r.opts.MaxAttempts)
+	}
+	return clause.Expr{SQL: sql + ")", Vars: vars}
+}
+
+// Purge deletes records delivered before the given time and returns the number of deleted records.
+func (r *Relay) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx := r.db.WithContext(ctx).Where("deliver_time < ?", before).Delete(&Record{})
+	return tx.RowsAffected, tx.Error
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.Backoff
+	for i := 1; i < attempts && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.opts.MaxBackoff {
+		d = r.opts.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRelay_Process(t *testing.T) {
+	db, mock := newMockDB(t)
+	columns := []string{"id", "topic", "key", "payload", "attempts"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM `outbox_records` WHERE (deliver_time IS NULL AND next_attempt_time <= ?) AND attempts < ? AND "+
+			"(`key` = '' OR NOT EXISTS (SELECT 1 FROM outbox_records AS earlier WHERE earlier.`key` = outbox_records.`key` "+
+			"AND earlier.id < outbox_records.id AND earlier.deliver_time IS NULL AND earlier.next_attempt_time > ? AND earlier.attempts < ?)) "+
+			"ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED",
+	)).WithArgs(sqlmock.AnyArg(), 3, sqlmock.AnyArg(), 3).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, "a", "k", []byte("1"), 0).
+		AddRow(2, "b", "k", []byte("2"), 1).
+		AddRow(3, "c", "k", []byte("3"), 0).
+		AddRow(4, "d", "", []byte("4"), 0))
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `outbox_records` SET `attempts`=?,`last_error`=?,`next_attempt_time`=?,`update_time`=? WHERE `id` = ?",
+	)).WithArgs(2, "unavailable", sqlmock.AnyArg(), sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `outbox_records` SET `deliver_time`=?,`update_time`=? WHERE id IN (?,?)",
+	)).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	pub := &MemoryPublisher{Fail: func(r *Record) error {
+		if r.Topic == "b" {
+			return errors.New("unavailable")
+		}
+		return nil
+	}}
+	n, err := NewRelay(db, pub, RelayOptions{BatchSize: 10, MaxAttempts: 3}).Process(context.Background())
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Process() = %v, want 2", n)
+	}
+	// Record 3 is held back by the failure of record 2 with the same key
+	if records := pub.Records(); len(records) != 2 || records[0].ID != 1 || records[1].ID != 4 {
+		t.Errorf("Records() = %+v, want records 1 and 4", records)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `outbox_records`").WillReturnRows(sqlmock.NewRows([]string{"id", "topic"}).AddRow(1, "a"))
+	mock.ExpectExec("UPDATE `outbox_records` SET `deliver_time`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pub := &MemoryPublisher{}
+	done := make(chan error)
+	go func() {
+		done <- NewRelay(db, pub, RelayOptions{PollInterval: time.Hour}).Run(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+	if len(pub.Records()) != 1 {
+		t.Errorf("Records() = %+v, want 1 record", pub.Records())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// logWriter sends the lines written to it to a channel.
+type logWriter chan string
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestRelay_Run_logsErrors(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin().WillReturnError(errors.New("connection lost"))
+
+	logged := make(logWriter, 1)
+	log.SetOutput(logged)
+	defer log.SetOutput(os.Stderr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewRelay(db, &MemoryPublisher{}, RelayOptions{PollInterval: time.Hour}).Run(ctx)
+	}()
+	select {
+	case line := <-logged:
+		if !strings.Contains(line, "connection lost") {
+			t.Errorf("logged %q, want the error of Process", line)
+		}
+	case <-time.After(time.Second):
+		t.Error("Run() did not log the error of Process")
+	}
+	cancel()
+	<-done
+}
+
+func TestRelay_backoff(t *testing.T) {
+	r := NewRelay(nil, nil, RelayOptions{Backoff: time.Second, MaxBackoff: 10 * time.Second})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -8,107 +8,302 @@ import (
 	"strings"
 
 	filter "github.com/ahiho/gocandy/filter/adapter/sql"
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/listing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaginateRequest struct {
 	PageSize int
 
-	// For now we only support offset
-	// Example: offset:100 (b2Zmc2V0OjEwMA)
+	// PageToken is a token returned by a previous call in listing format (base64 encoded).
+	// Tokens are rejected if any other parameter of the request changed between pages.
+	// Tokens of the previous "offset:N" format are still accepted.
 	PageToken string
 	Filter    string
 	Adaptor   *filter.SQLAdaptor
+
+	// OrderBy is an AIP-132 ordering clause, e.g. "name desc, create_time".
+	OrderBy string
+	// ShowDeleted includes soft deleted rows (see model.SoftDelete).
+	ShowDeleted bool
+	// Collection the page token is bound to. Defaults to the table name.
+	Collection string
+
+	// TotalSize controls whether and how the total number of matching rows is computed.
+	// It is only reported by PaginateResult and PaginateTransformResult.
+	TotalSize TotalSizeMode
+}
+
+// TotalSizeMode selects how the total size of a paginated query is computed.
+type TotalSizeMode int
+
+const (
+	// TotalSizeNone does not compute the total size.
+	TotalSizeNone TotalSizeMode = iota
+	// TotalSizeExact runs a separate COUNT query with the same filter.
+	TotalSizeExact
+	// TotalSizeEstimated reads the approximate row count of the table from information_schema,
+	// which is much cheaper than COUNT on big tables. It ignores conditions set on db by the caller
+	// and falls back to TotalSizeExact when a filter is given.
+	TotalSizeEstimated
+)
+
+// PageResult is a single page of a paginated query together with its page metadata.
+type PageResult[T any] struct {
+	Items []T
+	// NextPageToken is empty on the last page.
+	NextPageToken string
+	// PrevPageToken is empty on the first page.
+	PrevPageToken string
+	HasNextPage   bool
+	// PageNumber is the 1-based index of the page.
+	PageNumber int
+	// TotalSize and PageCount are only set when requested through PaginateRequest.TotalSize.
+	TotalSize int64
+	PageCount int
 }
 
 const (
 	DefaultPageSize = 10
 
-	offsetPrefix = "offset:"
-	empty        = ""
+	empty = ""
+	// legacyOffsetPrefix starts the page tokens of the previous format, e.g. "offset:100".
+	legacyOffsetPrefix = "offset:"
+
+	estimatedRowsQuery = "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
 )
 
 var (
 	ErrInvalidPageToken = errors.New("invalid page token")
 )
 
+// NewPaginateRequest returns a PaginateRequest for a listing.Request.
+// The page token of req is expected to be a token returned by a previous call to Paginate.
+func NewPaginateRequest(req listing.Request, adaptor *filter.SQLAdaptor) PaginateRequest {
+	return PaginateRequest{
+		PageSize:    req.Knobs.PageSize,
+		PageToken:   string(req.PageToken),
+		Filter:      req.Knobs.Filter,
+		Adaptor:     adaptor,
+		OrderBy:     req.Knobs.OrderBy,
+		ShowDeleted: req.Knobs.ShowDeleted,
+		Collection:  req.Collection,
+	}
+}
+
 func Paginate[T any](db *gorm.DB, pr PaginateRequest) ([]T, string, error) {
-	pageSize, offset, err := pr.getQueryParams()
+	rs, err := PaginateResult[T](db, pr)
 	if err != nil {
 		return nil, empty, err
 	}
 
-	rs := []T{}
-	tx, err := Filter(db, pr.Filter, pr.Adaptor)
+	return rs.Items, rs.NextPageToken, nil
+}
+
+func PaginateTransform[T any, R any](db *gorm.DB, pr PaginateRequest, fn func(i T) (o R, e error)) ([]R, string, error) {
+	rs, err := PaginateTransformResult(db, pr, fn)
 	if err != nil {
 		return nil, empty, err
 	}
 
-	tx = tx.Offset(offset).Limit(pageSize).Find(&rs)
-	if tx.Error != nil {
-		return nil, empty, tx.Error
+	return rs.Items, rs.NextPageToken, nil
+}
+
+// PaginateResult works like Paginate but returns the page together with its metadata.
+func PaginateResult[T any](db *gorm.DB, pr PaginateRequest) (*PageResult[T], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
 	}
 
-	return rs, nextToken(offset, pageSize, len(rs)), nil
-}
+	p, err := pr.pagination(stmt.Schema.Table)
+	if err != nil {
+		return nil, err
+	}
+	pageSize, offset := p.Knobs.PageSize, p.State.Offset
 
-func PaginateTransform[T any, R any](db *gorm.DB, pr PaginateRequest, fn func(i T) (o R, e error)) ([]R, string, error) {
-	pageSize, offset, err := pr.getQueryParams()
+	order, err := OrderBy(stmt.Schema, pr.OrderBy)
 	if err != nil {
-		return nil, empty, err
+		return nil, err
 	}
 
-	rs := []T{}
+	if pr.ShowDeleted {
+		db = db.Unscoped()
+	}
 	tx, err := Filter(db, pr.Filter, pr.Adaptor)
 	if err != nil {
-		return nil, empty, err
+		return nil, err
 	}
+	// Make tx reusable for both the page and the count query
+	tx = tx.Session(&gorm.Session{})
 
-	tx = tx.Offset(offset).Limit(pageSize).Find(&rs)
-	if tx.Error != nil {
-		return nil, empty, tx.Error
+	rs := []T{}
+	// Fetch one extra row to find out whether there is a next page
+	q := tx.Clauses(clause.OrderBy{Columns: order}).Offset(offset).Limit(pageSize + 1)
+	if err := q.Find(&rs).Error; err != nil {
+		return nil, err
+	}
+
+	res := &PageResult[T]{
+		Items:      rs,
+		PageNumber: offset/pageSize + 1,
+	}
+	if len(rs) > pageSize {
+		res.Items = rs[:pageSize]
+		res.HasNextPage = true
+		if res.NextPageToken, err = p.token(offset + pageSize); err != nil {
+			return nil, err
+		}
+	}
+	if offset > 0 {
+		prev := offset - pageSize
+		if prev < 0 {
+			prev = 0
+		}
+		if res.PrevPageToken, err = p.token(prev); err != nil {
+			return nil, err
+		}
+	}
+
+	if pr.TotalSize != TotalSizeNone {
+		total, err := totalSize[T](tx, pr)
+		if err != nil {
+			return nil, err
+		}
+		res.TotalSize = total
+		res.PageCount = int((total + int64(pageSize) - 1) / int64(pageSize))
+	}
+
+	return res, nil
+}
+
+// PaginateTransformResult works like PaginateTransform but returns the page together with its metadata.
+func PaginateTransformResult[T any, R any](db *gorm.DB, pr PaginateRequest, fn func(i T) (o R, e error)) (*PageResult[R], error) {
+	rs, err := PaginateResult[T](db, pr)
+	if err != nil {
+		return nil, err
 	}
 
 	r := []R{}
-	for _, i := range rs {
+	for _, i := range rs.Items {
 		o, e := fn(i)
 		if e != nil {
-			return nil, empty, e
+			return nil, e
 		}
 		r = append(r, o)
 	}
 
-	return r, nextToken(offset, pageSize, len(rs)), nil
+	return &PageResult[R]{
+		Items:         r,
+		NextPageToken: rs.NextPageToken,
+		PrevPageToken: rs.PrevPageToken,
+		HasNextPage:   rs.HasNextPage,
+		PageNumber:    rs.PageNumber,
+		TotalSize:     rs.TotalSize,
+		PageCount:     rs.PageCount,
+	}, nil
+}
+
+func totalSize[T any](tx *gorm.DB, pr PaginateRequest) (int64, error) {
+	if pr.TotalSize == TotalSizeEstimated && pr.Filter == "" {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(new(T)); err != nil {
+			return 0, err
+		}
+
+		var rows *int64
+		err := tx.Session(&gorm.Session{NewDB: true}).Raw(estimatedRowsQuery, stmt.Schema.Table).Scan(&rows).Error
+		if err != nil {
+			return 0, err
+		}
+		// Views and unknown tables have no estimate
+		if rows != nil {
+			return *rows, nil
+		}
+	}
+
+	var total int64
+	if err := tx.Model(new(T)).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// offsetPagination implements listing.Pagination for offset based pagination.
+type offsetPagination struct {
+	listing.CommonState
+	State offsetState
+
+	hasNextPage bool
+}
+
+type offsetState struct {
+	Offset int `json:"o"`
 }
 
-func (p PaginateRequest) getQueryParams() (offset int, pageSize int, err error) {
-	pageSize = p.PageSize
-	if p.PageSize < 1 {
-		pageSize = DefaultPageSize
+func (p *offsetPagination) HasNextPage() bool             { return p.hasNextPage }
+func (p *offsetPagination) ImplState() interface{}        { return p.State }
+func (p *offsetPagination) ModelHook(model *model.Common) {}
+func (p *offsetPagination) Finish()                       {}
+
+// pagination decodes the page token of p and validates it against the other parameters.
+func (p PaginateRequest) pagination(table string) (*offsetPagination, error) {
+	req := listing.Request{
+		Knobs: listing.Knobs{
+			ShowDeleted: p.ShowDeleted,
+			PageSize:    p.PageSize,
+			Filter:      p.Filter,
+			OrderBy:     p.OrderBy,
+		},
+		Collection: p.Collection,
+	}
+	if req.Knobs.PageSize < 1 {
+		req.Knobs.PageSize = DefaultPageSize
 	}
-	offset = 0
-	if p.PageToken == "" {
-		return pageSize, 0, nil
+	if req.Collection == "" {
+		req.Collection = table
+	}
+	legacyOffset := -1
+	if p.PageToken != "" {
+		pt, err := base64.RawURLEncoding.DecodeString(p.PageToken)
+		if err != nil {
+			return nil, ErrInvalidPageToken
+		}
+		if offset, ok := strings.CutPrefix(string(pt), legacyOffsetPrefix); ok {
+			// Tokens of the previous format only contain the offset, the parameters are not checked.
+			if legacyOffset, err = strconv.Atoi(offset); err != nil || legacyOffset < 0 {
+				return nil, ErrInvalidPageToken
+			}
+		} else {
+			req.PageToken = pt
+		}
 	}
-	pt, e := base64.RawURLEncoding.DecodeString(p.PageToken)
-	if e != nil {
-		return 0, 0, e
+
+	op := &offsetPagination{}
+	if err := listing.Init(req, &op.CommonState, &op.State); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
 	}
-	token := string(pt)
-	if !strings.HasPrefix(token, offsetPrefix) {
-		return 0, 0, ErrInvalidPageToken
+	if legacyOffset >= 0 {
+		op.State.Offset = legacyOffset
 	}
-	ofs := token[len(offsetPrefix):]
-	offset, e = strconv.Atoi(ofs)
-	if e != nil || offset < 0 {
-		return 0, 0, ErrInvalidPageToken
+	if op.State.Offset < 0 {
+		return nil, ErrInvalidPageToken
 	}
-	return pageSize, offset, nil
+	return op, nil
 }
 
-func nextToken(offset int, pageSize int, count int) string {
-	if count < pageSize {
-		return empty
+// token returns a page token pointing at offset with the same parameters as p.
+func (p *offsetPagination) token(offset int) (string, error) {
+	next := &offsetPagination{
+		CommonState: p.CommonState,
+		State:       offsetState{Offset: offset},
+		hasNextPage: true,
+	}
+	pt, err := listing.Finish(next)
+	if err != nil {
+		return empty, err
 	}
-	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%v%v", offsetPrefix, offset+count)))
+	return base64.RawURLEncoding.EncodeToString(pt), nil
 }
@@ -0,0 +1,222 @@
+package gormx
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/listing"
+)
+
+type testArticle struct {
+	model.Common
+	model.SoftDelete
+	Title string
+}
+
+// pageToken returns the token Paginate emits for offset with the parameters of pr.
+func pageToken(t *testing.T, pr PaginateRequest, table string, offset int) string {
+	t.Helper()
+	p, err := pr.pagination(table)
+	if err != nil {
+		t.Fatalf("pagination() error = %v", err)
+	}
+	token, err := p.token(offset)
+	if err != nil {
+		t.Fatalf("token() error = %v", err)
+	}
+	return token
+}
+
+func userRows(ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name"})
+	for _, id := range ids {
+		rows.AddRow(id, "user")
+	}
+	return rows
+}
+
+func TestPaginateResult(t *testing.T) {
+	size2 := PaginateRequest{PageSize: 2}
+	size2Total := PaginateRequest{PageSize: 2, TotalSize: TotalSizeExact}
+	tests := []struct {
+		name       string
+		req        PaginateRequest
+		expect     func(mock sqlmock.Sqlmock)
+		wantItems  int
+		wantNext   string
+		wantPrev   string
+		wantPage   int
+		wantTotal  int64
+		wantPages  int
+		wantHasNxt bool
+	}{
+		{
+			name: "first page with next page",
+			req:  PaginateRequest{PageSize: 2},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 3")).
+					WillReturnRows(userRows(1, 2, 3))
+			},
+			wantItems:  2,
+			wantNext:   pageToken(t, size2, "test_users", 2),
+			wantPage:   1,
+			wantHasNxt: true,
+		},
+		{
+			name: "last page exactly full",
+			req:  PaginateRequest{PageSize: 2, PageToken: pageToken(t, size2, "test_users", 2)},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 3 OFFSET 2")).
+					WillReturnRows(userRows(3, 4))
+			},
+			wantItems: 2,
+			wantPrev:  pageToken(t, size2, "test_users", 0),
+			wantPage:  2,
+		},
+		{
+			name: "exact total size",
+			req:  PaginateRequest{PageSize: 2, PageToken: pageToken(t, size2Total, "test_users", 4), TotalSize: TotalSizeExact},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 3 OFFSET 4")).
+					WillReturnRows(userRows(5))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `test_users`")).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+			},
+			wantItems: 1,
+			wantPrev:  pageToken(t, size2Total, "test_users", 2),
+			wantPage:  3,
+			wantTotal: 5,
+			wantPages: 3,
+		},
+		{
+			name: "estimated total size",
+			req:  PaginateRequest{PageSize: 2, TotalSize: TotalSizeEstimated},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_users` ORDER BY `test_users`.`id` LIMIT 3")).
+					WillReturnRows(userRows(1, 2, 3))
+				mock.ExpectQuery(regexp.QuoteMeta(estimatedRowsQuery)).
+					WithArgs("test_users").
+					WillReturnRows(sqlmock.NewRows([]string{"TABLE_ROWS"}).AddRow(1000))
+			},
+			wantItems:  2,
+			wantNext:   pageToken(t, PaginateRequest{PageSize: 2, TotalSize: TotalSizeEstimated}, "test_users", 2),
+			wantPage:   1,
+			wantTotal:  1000,
+			wantPages:  500,
+			wantHasNxt: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			tt.expect(mock)
+
+			got, err := PaginateResult[testUser](db, tt.req)
+			if err != nil {
+				t.Fatalf("PaginateResult() error = %v", err)
+			}
+			if len(got.Items) != tt.wantItems {
+				t.Errorf("PaginateResult() items = %v, want %v", len(got.Items), tt.wantItems)
+			}
+			if got.NextPageToken != tt.wantNext || got.HasNextPage != tt.wantHasNxt {
+				t.Errorf("PaginateResult() next = %q (%v), want %q (%v)", got.NextPageToken, got.HasNextPage, tt.wantNext, tt.wantHasNxt)
+			}
+			if got.PrevPageToken != tt.wantPrev {
+				t.Errorf("PaginateResult() prev = %q, want %q", got.PrevPageToken, tt.wantPrev)
+			}
+			if got.PageNumber != tt.wantPage || got.TotalSize != tt.wantTotal || got.PageCount != tt.wantPages {
+				t.Errorf("PaginateResult() page = %v/%v total = %v, want %v/%v total = %v",
+					got.PageNumber, got.PageCount, got.TotalSize, tt.wantPage, tt.wantPages, tt.wantTotal)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPaginateResult_listing(t *testing.T) {
+	t.Run("order by and soft delete", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			"SELECT * FROM `test_articles` WHERE `test_articles`.`delete_time` IS NULL ORDER BY `test_articles`.`title` DESC,`test_articles`.`id` LIMIT 11",
+		)).WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+
+		_, err := PaginateResult[testArticle](db, PaginateRequest{OrderBy: "title desc"})
+		if err != nil {
+			t.Fatalf("PaginateResult() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("show deleted", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_articles` ORDER BY `test_articles`.`id` LIMIT 11")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+
+		req := listing.Request{Knobs: listing.Knobs{ShowDeleted: true}}
+		_, err := PaginateResult[testArticle](db, NewPaginateRequest(req, nil))
+		if err != nil {
+			t.Fatalf("PaginateResult() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("changed parameters", func(t *testing.T) {
+		db, _ := newMockDB(t)
+		token := pageToken(t, PaginateRequest{OrderBy: "title"}, "test_articles", 10)
+
+		_, err := PaginateResult[testArticle](db, PaginateRequest{OrderBy: "title desc", PageToken: token})
+		if !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("PaginateResult() error = %v, want %v", err, ErrInvalidPageToken)
+		}
+	})
+
+	t.Run("other collection", func(t *testing.T) {
+		db, _ := newMockDB(t)
+		token := pageToken(t, PaginateRequest{}, "test_users", 10)
+
+		_, err := PaginateResult[testArticle](db, PaginateRequest{PageToken: token})
+		if !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("PaginateResult() error = %v, want %v", err, ErrInvalidPageToken)
+		}
+	})
+
+	t.Run("legacy token", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			"SELECT * FROM `test_articles` WHERE `test_articles`.`delete_time` IS NULL ORDER BY `test_articles`.`id` LIMIT 11 OFFSET 20",
+		)).WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+
+		token := base64.RawURLEncoding.EncodeToString([]byte("offset:20"))
+		got, err := PaginateResult[testArticle](db, PaginateRequest{PageToken: token})
+		if err != nil {
+			t.Fatalf("PaginateResult() error = %v", err)
+		}
+		if want := pageToken(t, PaginateRequest{}, "test_articles", 10); got.PrevPageToken != want {
+			t.Errorf("PaginateResult() prev = %q, want %q in the listing format", got.PrevPageToken, want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("invalid legacy token", func(t *testing.T) {
+		db, _ := newMockDB(t)
+		for _, token := range []string{"offset:-1", "offset:x"} {
+			_, err := PaginateResult[testArticle](db, PaginateRequest{PageToken: base64.RawURLEncoding.EncodeToString([]byte(token))})
+			if !errors.Is(err, ErrInvalidPageToken) {
+				t.Errorf("PaginateResult(%s) error = %v, want %v", token, err, ErrInvalidPageToken)
+			}
+		}
+	})
+}
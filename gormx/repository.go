@@ -0,0 +1,224 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ahiho/gocandy/apperror"
+	"github.com/ahiho/gocandy/fieldmask"
+	filter "github.com/ahiho/gocandy/filter/adapter/sql"
+	"github.com/ahiho/gocandy/idgen"
+	"github.com/ahiho/gocandy/resource"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrNoSoftDelete = errors.New("model does not support soft delete")
+)
+
+// Repository implements the common CRUD operations for a model T embedding model.Common.
+// Soft deletion requires T to embed model.SoftDelete, optimistic locking requires T to embed model.Version.
+type Repository[T any] struct {
+	db      *gorm.DB
+	adaptor *filter.SQLAdaptor
+	schema  *schema.Schema
+}
+
+// NewRepository returns a Repository for T. adaptor is used to parse filters in List.
+func NewRepository[T any](db *gorm.DB, adaptor *filter.SQLAdaptor) (*Repository[T], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, ErrNoPrimaryKey
+	}
+
+	return &Repository[T]{
+		db:      db,
+		adaptor: adaptor,
+		schema:  stmt.Schema,
+	}, nil
+}
+
+// Get returns the model with the given ID.
+func (r *Repository[T]) Get(ctx context.Context, id int64) (*T, error) {
+	return r.get(DBFromContext(ctx, r.db), id)
+}
+
+// List returns a page of models. The repository adaptor is used if pr.Adaptor is not set.
+// Invalid page tokens, filters and orders are returned as bad requests wrapping the gormx errors.
+func (r *Repository[T]) List(ctx context.Context, pr PaginateRequest) (*PageResult[T], error) {
+	if pr.Adaptor == nil {
+		pr.Adaptor = r.adaptor
+	}
+
+	res, err := PaginateResult[T](DBFromContext(ctx, r.db), pr)
+	if errors.Is(err, ErrInvalidPageToken) || errors.Is(err, ErrInvalidFilter) || errors.Is(err, ErrInvalidOrderBy) {
+		return nil, apperror.BadRequestWithCodeE(apperror.ErrBadRequest, err.Error(), err)
+	}
+	return res, err
+}
+
+// Create inserts m, assigning a new ID from idgen if it has none.
+func (r *Repository[T]) Create(ctx context.Context, m *T) error {
+	rv := reflect.ValueOf(m).Elem()
+	pk := r.schema.PrioritizedPrimaryField
+	if _, zero := pk.ValueOf(ctx, rv); zero {
+		if err := pk.Set(ctx, rv, idgen.GenID()); err != nil {
+			return err
+		}
+	}
+
+	return DBFromContext(ctx, r.db).Create(m).Error
+}
+
+// Update writes the fields of m in mask, or all updatable fields if mask is empty.
+// Output only fields are never written. If T embeds model.Version, the update only succeeds when the
+// stored version equals the version of m, which is incremented on success (see UpdateWithVersion).
+func (r *Repository[T]) Update(ctx context.Context, m *T, mask *fieldmask.Mask) error {
+	columns, err := updateColumns[T](r.schema, mask)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(m).Elem()
+	id, _ := r.schema.PrioritizedPrimaryField.ValueOf(ctx, rv)
+	db := DBFromContext(ctx, r.db)
+
+	version := r.schema.LookUpField(VersionColumn)
+	if version == nil {
+		tx := db.Model(m).Select(columns).Updates(m)
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if tx.RowsAffected == 0 {
+			_, err := r.get(db, id)
+			return err
+		}
+		return nil
+	}
+
+	current, _ := version.ValueOf(ctx, rv)
+	expected, ok := current.(uint64)
+	if !ok {
+		return fmt.Errorf("unsupported version type %T", current)
+	}
+	err = UpdateWithVersion(db, m, expected, columns...)
+	if errors.Is(err, ErrVersionConflict) {
+		if _, err := r.get(db, id); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Delete deletes the model with the given ID. The model is soft deleted if T embeds model.SoftDelete.
+func (r *Repository[T]) Delete(ctx context.Context, id int64) error {
+	tx := DBFromContext(ctx, r.db).Where(r.primaryKey(id)).Delete(new(T))
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return r.notFound(id)
+	}
+	return nil
+}
+
+// Undelete restores a soft deleted model.
+func (r *Repository[T]) Undelete(ctx context.Context, id int64) error {
+	deleteTime := r.deleteTimeField()
+	if deleteTime == nil {
+		return ErrNoSoftDelete
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: deleteTime.DBName}
+	tx := DBFromContext(ctx, r.db).Unscoped().Model(new(T)).
+		Where(r.primaryKey(id)).
+		Where(clause.Neq{Column: column, Value: nil}).
+		Update(deleteTime.DBName, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := r.get(DBFromContext(ctx, r.db).Unscoped(), id); err != nil {
+		return err
+	}
+	return apperror.BadRequest(fmt.Sprintf("%v %v is not deleted", r.schema.Name, id))
+}
+
+func (r *Repository[T]) get(db *gorm.DB, id interface{}) (*T, error) {
+	m := new(T)
+	if err := db.Where(r.primaryKey(id)).Take(m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, r.notFound(id)
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *Repository[T]) primaryKey(id interface{}) clause.Expression {
+	return clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: r.schema.PrioritizedPrimaryField.DBName},
+		Value:  id,
+	}
+}
+
+func (r *Repository[T]) notFound(id interface{}) error {
+	return apperror.NotFound(fmt.Sprintf("%v %v not found", r.schema.Name, id))
+}
+
+// updateColumns returns the columns of the model T with schema s to update for mask,
+// or all updatable columns if mask is empty. Output only fields are left out.
+func updateColumns[T any](s *schema.Schema, mask *fieldmask.Mask) ([]string, error) {
+	var res resource.Resource
+	if rr, ok := any(new(T)).(resource.Resource); ok {
+		res = rr
+	}
+	outputOnly := func(f *schema.Field) bool {
+		return f.PrimaryKey || f.DBName == VersionColumn || (res != nil && res.IsFieldOutputOnly(f.DBName))
+	}
+
+	columns := []string{}
+	if mask == nil || len(mask.Fields) == 0 {
+		for _, f := range s.Fields {
+			if f.DBName != "" && f.Updatable && !outputOnly(f) {
+				columns = append(columns, f.DBName)
+			}
+		}
+		return columns, nil
+	}
+
+	for _, name := range mask.Fields {
+		f := s.LookUpField(name)
+		if f == nil || f.DBName == "" || !f.Updatable {
+			return nil, apperror.BadRequest(fmt.Sprintf("unknown field %q in update mask", name))
+		}
+		if outputOnly(f) {
+			continue
+		}
+		columns = append(columns, f.DBName)
+	}
+	if len(columns) == 0 {
+		return nil, apperror.BadRequest("update mask contains no updatable fields")
+	}
+	return columns, nil
+}
+
+func (r *Repository[T]) deleteTimeField() *schema.Field {
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+	for _, f := range r.schema.Fields {
+		if f.FieldType == deletedAt {
+			return f
+		}
+	}
+	return nil
+}
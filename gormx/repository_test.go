@@ -0,0 +1,191 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/ahiho/gocandy/apperror"
+	"github.com/ahiho/gocandy/fieldmask"
+	"github.com/ahiho/gocandy/gormx/model"
+	"github.com/ahiho/gocandy/idgen"
+)
+
+type testProfile struct {
+	model.Common
+	model.SoftDelete
+	model.Version
+	Name  string
+	Email string
+}
+
+func newTestRepository(t *testing.T) (*Repository[testProfile], sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	r, err := NewRepository[testProfile](db, nil)
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	return r, mock
+}
+
+func isNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "apperror:code="+apperror.ErrNotFound)
+}
+
+func TestRepository_Get(t *testing.T) {
+	r, mock := newTestRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM `test_profiles` WHERE `test_profiles`.`id` = ? AND `test_profiles`.`delete_time` IS NULL LIMIT 1",
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := r.Get(context.Background(), 1)
+	if !isNotFound(err) {
+		t.Errorf("Get() error = %v, want not found", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRepository_List(t *testing.T) {
+	r, _ := newTestRepository(t)
+	tests := []struct {
+		name string
+		pr   PaginateRequest
+		want error
+	}{
+		{"page token", PaginateRequest{PageSize: 10, PageToken: "invalid"}, ErrInvalidPageToken},
+		{"order by", PaginateRequest{PageSize: 10, OrderBy: "unknown"}, ErrInvalidOrderBy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.List(context.Background(), tt.pr)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("List() error = %v, want %v", err, tt.want)
+			}
+			if code := apperror.CodeOf(err); code != apperror.ErrBadRequest {
+				t.Errorf("List() code = %v, want %v", code, apperror.ErrBadRequest)
+			}
+		})
+	}
+}
+
+func TestRepository_Create(t *testing.T) {
+	if err := idgen.MustInit(1, 10, 12); err != nil {
+		t.Fatal(err)
+	}
+	r, mock := newTestRepository(t)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_profiles`")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	p := &testProfile{Name: "name"}
+	if err := r.Create(context.Background(), p); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if p.ID == 0 {
+		t.Errorf("Create() did not assign an ID")
+	}
+}
+
+func TestRepository_Update(t *testing.T) {
+	updateQuery := regexp.QuoteMeta("UPDATE `test_profiles` SET `update_time`=?,`version`=?,`name`=? WHERE `test_profiles`.`version` = ? AND `test_profiles`.`delete_time` IS NULL AND `id` = ?")
+	getQuery := regexp.QuoteMeta("SELECT * FROM `test_profiles` WHERE `test_profiles`.`id` = ?")
+	mask := &fieldmask.Mask{Fields: []string{"name", "create_time"}}
+
+	t.Run("success", func(t *testing.T) {
+		r, mock := newTestRepository(t)
+		mock.ExpectExec(updateQuery).
+			WithArgs(sqlmock.AnyArg(), 4, "new", 3, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p := &testProfile{Common: model.Common{ID: 1}, Version: model.Version{Version: 3}, Name: "new"}
+		if err := r.Update(context.Background(), p, mask); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if p.Version.Version != 4 {
+			t.Errorf("Update() version = %v, want 4", p.Version.Version)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		r, mock := newTestRepository(t)
+		mock.ExpectExec(updateQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(getQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 5))
+
+		p := &testProfile{Common: model.Common{ID: 1}, Version: model.Version{Version: 3}, Name: "new"}
+		err := r.Update(context.Background(), p, mask)
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Update() error = %v, want %v", err, ErrVersionConflict)
+		}
+		if p.Version.Version != 3 {
+			t.Errorf("Update() version = %v, want 3", p.Version.Version)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r, mock := newTestRepository(t)
+		mock.ExpectExec(updateQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(getQuery).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		p := &testProfile{Common: model.Common{ID: 1}, Name: "new"}
+		if err := r.Update(context.Background(), p, mask); !isNotFound(err) {
+			t.Errorf("Update() error = %v, want not found", err)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		r, _ := newTestRepository(t)
+		p := &testProfile{Common: model.Common{ID: 1}}
+		err := r.Update(context.Background(), p, &fieldmask.Mask{Fields: []string{"password"}})
+		if err == nil || !strings.HasPrefix(err.Error(), "apperror:code="+apperror.ErrBadRequest) {
+			t.Errorf("Update() error = %v, want bad request", err)
+		}
+	})
+}
+
+func Test_updateColumns(t *testing.T) {
+	r, _ := newTestRepository(t)
+	got, err := updateColumns[testProfile](r.schema, nil)
+	if err != nil {
+		t.Fatalf("updateColumns() error = %v", err)
+	}
+	if strings.Join(got, ",") != "name,email" {
+		t.Errorf("updateColumns() = %v, want [name email]", got)
+	}
+}
+
+func TestRepository_Delete(t *testing.T) {
+	r, mock := newTestRepository(t)
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `test_profiles` SET `delete_time`=? WHERE `test_profiles`.`id` = ? AND `test_profiles`.`delete_time` IS NULL",
+	)).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := r.Delete(context.Background(), 1); !isNotFound(err) {
+		t.Errorf("Delete() error = %v, want not found", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRepository_Undelete(t *testing.T) {
+	r, mock := newTestRepository(t)
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `test_profiles` SET `delete_time`=?,`update_time`=? WHERE `test_profiles`.`id` = ? AND `test_profiles`.`delete_time` IS NOT NULL",
+	)).WithArgs(nil, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := r.Undelete(context.Background(), 1); err != nil {
+		t.Errorf("Undelete() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,162 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ahiho/gocandy/xcontext"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTxMaxRetries = 3
+	DefaultTxBackoff    = 50 * time.Millisecond
+	DefaultTxMaxBackoff = time.Second
+)
+
+// TxOptions configures a TxManager.
+type TxOptions struct {
+	// MaxRetries is the number of times a transaction is retried after a deadlock or lock wait timeout.
+	// Defaults to DefaultTxMaxRetries, a negative value disables retries.
+	MaxRetries int
+	// Backoff is the delay before the first retry, it doubles with every retry up to MaxBackoff.
+	// Defaults to DefaultTxBackoff and DefaultTxMaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// TxOptions are passed to BeginTx. Optional.
+	TxOptions *sql.TxOptions
+}
+
+// TxManager runs functions in transactions carried by the context.
+// Code receiving the context picks up the transaction with DBFromContext, Repository does so automatically.
+type TxManager struct {
+	db   *gorm.DB
+	opts TxOptions
+}
+
+type txKey struct{}
+
+// txState is the state of a transaction or savepoint stored in the context.
+type txState struct {
+	tx *gorm.DB
+
+	mu    sync.Mutex
+	hooks []func(ctx context.Context)
+}
+
+// NewTxManager returns a TxManager running transactions on db.
+func NewTxManager(db *gorm.DB, opts TxOptions) *TxManager {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultTxMaxRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultTxBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultTxMaxBackoff
+	}
+	return &TxManager{
+		db:   db,
+		opts: opts,
+	}
+}
+
+// Do runs fn in a transaction which is committed if fn returns nil and rolled back otherwise.
+// The transaction is available to fn through DBFromContext.
+//
+// If ctx already carries a transaction, fn runs in a savepoint of it and only the savepoint
+// is rolled back on error. Otherwise the whole transaction is retried with backoff if it fails
+// with a MySQL deadlock or lock wait timeout, so fn must be safe to run more than once.
+func (m *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if parent, ok := ctx.Value(txKey{}).(*txState); ok {
+		child := &txState{}
+		err := parent.tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			child.tx = tx
+			return fn(context.WithValue(ctx, txKey{}, child))
+		})
+		if err == nil {
+			parent.addHooks(child.hooks...)
+		}
+		return err
+	}
+
+	backoff := m.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		state := &txState{}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			state.tx = tx
+			return fn(context.WithValue(ctx, txKey{}, state))
+		}, m.opts.TxOptions)
+		if err == nil {
+			state.runHooks(xcontext.Detach(ctx))
+			return nil
+		}
+		if attempt >= m.opts.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		// Full jitter spreads retries of transactions which deadlocked each other
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > m.opts.MaxBackoff {
+			backoff = m.opts.MaxBackoff
+		}
+	}
+}
+
+// DBFromContext returns the transaction carried by ctx, or db if there is none, bound to ctx.
+func DBFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// AfterCommit registers fn to run once the transaction carried by ctx is committed.
+// Hooks registered in a savepoint are dropped if the savepoint is rolled back.
+// fn receives a context detached from the cancellation of ctx (see xcontext.Detach).
+// If ctx carries no transaction, fn runs immediately.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.addHooks(fn)
+		return
+	}
+	fn(xcontext.Detach(ctx))
+}
+
+// IsRetryable reports whether err is a MySQL deadlock or lock wait timeout, after which
+// the transaction can be retried.
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+	return false
+}
+
+func (s *txState) addHooks(hooks ...func(ctx context.Context)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hooks...)
+}
+
+func (s *txState) runHooks(ctx context.Context) {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
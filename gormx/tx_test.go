@@ -0,0 +1,162 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestTxManager_Do(t *testing.T) {
+	insert := regexp.QuoteMeta("INSERT INTO `test_users` (`name`,`id`) VALUES (?,?)")
+
+	t.Run("commit", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(insert).WithArgs("a", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		committed := false
+		err := NewTxManager(db, TxOptions{}).Do(context.Background(), func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { committed = true })
+			if committed {
+				t.Error("AfterCommit() hook ran before commit")
+			}
+			return DBFromContext(ctx, db).Create(&testUser{ID: 1, Name: "a"}).Error
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if !committed {
+			t.Error("AfterCommit() hook did not run")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		want := errors.New("failed")
+		committed := false
+		err := NewTxManager(db, TxOptions{}).Do(context.Background(), func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { committed = true })
+			return want
+		})
+		if !errors.Is(err, want) {
+			t.Errorf("Do() error = %v, want %v", err, want)
+		}
+		if committed {
+			t.Error("AfterCommit() hook ran after rollback")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sp").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(insert).WithArgs("b", 2).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		m := NewTxManager(db, TxOptions{})
+		hooks := []string{}
+		err := m.Do(context.Background(), func(ctx context.Context) error {
+			_ = m.Do(ctx, func(ctx context.Context) error {
+				AfterCommit(ctx, func(context.Context) { hooks = append(hooks, "rolled back") })
+				return errors.New("failed")
+			})
+			return m.Do(ctx, func(ctx context.Context) error {
+				AfterCommit(ctx, func(context.Context) { hooks = append(hooks, "committed") })
+				return DBFromContext(ctx, db).Create(&testUser{ID: 2, Name: "b"}).Error
+			})
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if len(hooks) != 1 || hooks[0] != "committed" {
+			t.Errorf("AfterCommit() hooks = %v, want [committed]", hooks)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("retry deadlock", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		mock.ExpectBegin()
+		mock.ExpectExec(insert).WillReturnError(deadlock)
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec(insert).WithArgs("a", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		attempts := 0
+		err := NewTxManager(db, TxOptions{Backoff: time.Millisecond}).Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return DBFromContext(ctx, db).Create(&testUser{ID: 1, Name: "a"}).Error
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("Do() attempts = %v, want 2", attempts)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		timeout := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+		for i := 0; i < 2; i++ {
+			mock.ExpectBegin()
+			mock.ExpectExec(insert).WillReturnError(timeout)
+			mock.ExpectRollback()
+		}
+
+		err := NewTxManager(db, TxOptions{MaxRetries: 1, Backoff: time.Millisecond}).Do(context.Background(), func(ctx context.Context) error {
+			return DBFromContext(ctx, db).Create(&testUser{ID: 1, Name: "a"}).Error
+		})
+		if !IsRetryable(err) {
+			t.Errorf("Do() error = %v, want %v", err, timeout)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"duplicate entry", &mysql.MySQLError{Number: 1062}, false},
+		{"other", errors.New("failed"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package gormx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// VersionColumn is the column of model.Version.
+	VersionColumn = "version"
+
+	versionPluginName = "gormx:version"
+	setClause         = "SET"
+)
+
+var (
+	ErrVersionConflict = errors.New("version conflict")
+	ErrInvalidETag     = errors.New("invalid etag")
+)
+
+// VersionConflictError is returned when an update expecting a version matched no rows.
+// It matches ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	Expected uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected version %v", e.Expected)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// VersionPlugin is a gorm plugin which increments the version column on every update
+// of a model that has one (see model.Version).
+//
+//	db.Use(&gormx.VersionPlugin{})
+//
+// The new version is computed by the database, the version of the updated value is not changed.
+// Use UpdateWithVersion to keep it in sync.
+type VersionPlugin struct {
+	// Column is the version column, defaults to VersionColumn.
+	Column string
+}
+
+func (p *VersionPlugin) Name() string {
+	return versionPluginName
+}
+
+func (p *VersionPlugin) Initialize(db *gorm.DB) error {
+	column := p.Column
+	if column == "" {
+		column = VersionColumn
+	}
+
+	next := db.ClauseBuilders[setClause]
+	db.ClauseBuilders[setClause] = func(c clause.Clause, builder clause.Builder) {
+		if stmt, ok := builder.(*gorm.Statement); ok {
+			c.Expression = bumpVersion(stmt, c.Expression, column)
+		}
+		if next != nil {
+			next(c, builder)
+		} else {
+			c.Build(builder)
+		}
+	}
+	return nil
+}
+
+// bumpVersion replaces any assignment to the version column in expr by version + 1.
+func bumpVersion(stmt *gorm.Statement, expr clause.Expression, column string) clause.Expression {
+	set, ok := expr.(clause.Set)
+	if !ok || stmt.Schema == nil {
+		return expr
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return expr
+	}
+
+	bumped := make(clause.Set, 0, len(set)+1)
+	for _, a := range set {
+		if a.Column.Name != field.DBName {
+			bumped = append(bumped, a)
+		}
+	}
+	return append(bumped, clause.Assignment{
+		Column: clause.Column{Name: field.DBName},
+		Value:  gorm.Expr("? + 1", clause.Column{Name: field.DBName}),
+	})
+}
+
+// ExpectVersion returns a scope restricting a query to rows with the given version.
+//
+//	db.Scopes(gormx.ExpectVersion(3)).Updates(&m)
+func ExpectVersion(version uint64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: VersionColumn}, Value: version})
+	}
+}
+
+// UpdateWithVersion updates value like db.Updates, restricted to columns if any, but only if the stored row
+// has the expected version. The version of value is set to the new version on success.
+// Returns a VersionConflictError if no row was updated.
+func UpdateWithVersion(db *gorm.DB, value interface{}, expected uint64, columns ...string) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(VersionColumn)
+	if field == nil {
+		return fmt.Errorf("model %v has no %v field", stmt.Schema.Name, VersionColumn)
+	}
+
+	ctx := db.Statement.Context
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if err := field.Set(ctx, rv, expected+1); err != nil {
+		return err
+	}
+
+	tx := db.Model(value).Scopes(ExpectVersion(expected))
+	if len(columns) > 0 {
+		tx = tx.Select(append(columns[:len(columns):len(columns)], field.DBName))
+	}
+	tx = tx.Updates(value)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Error = &VersionConflictError{Expected: expected}
+	}
+	if tx.Error != nil {
+		// Keep the version of value in sync with the stored row
+		_ = field.Set(ctx, rv, expected)
+		return tx.Error
+	}
+	return nil
+}
+
+// UpdateWithETag works like UpdateWithVersion with the version parsed from etag.
+// An empty etag updates value unconditionally.
+func UpdateWithETag(db *gorm.DB, value interface{}, etag string, columns ...string) error {
+	if etag == "" {
+		tx := db.Model(value)
+		if len(columns) > 0 {
+			tx = tx.Select(columns)
+		}
+		return tx.Updates(value).Error
+	}
+
+	version, err := ParseETag(etag)
+	if err != nil {
+		return err
+	}
+	return UpdateWithVersion(db, value, version, columns...)
+}
+
+// ETag returns the AIP-154 etag of a resource version.
+func ETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// ParseETag returns the version of an etag created by ETag. Weak etags are accepted.
+func ParseETag(etag string) (uint64, error) {
+	etag = strings.TrimPrefix(etag, "W/")
+	if unquoted, err := strconv.Unquote(etag); err == nil {
+		etag = unquoted
+	}
+	version, err := strconv.ParseUint(etag, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidETag, etag)
+	}
+	return version, nil
+}
@@ -0,0 +1,101 @@
+package gormx
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/ahiho/gocandy/gormx/model"
+)
+
+func TestVersionPlugin(t *testing.T) {
+	db, mock := newMockDB(t)
+	if err := db.Use(&VersionPlugin{}); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `test_profiles` SET `name`=?,`update_time`=?,`version`=`version` + 1 WHERE `test_profiles`.`delete_time` IS NULL AND `id` = ?",
+	)).WithArgs("new", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `test_users` SET `name`=? WHERE `id` = ?",
+	)).WithArgs("new", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	p := &testProfile{Common: model.Common{ID: 1}, Version: model.Version{Version: 7}}
+	if err := db.Model(p).Updates(map[string]interface{}{"name": "new", "version": 1}).Error; err != nil {
+		t.Fatalf("Updates() error = %v", err)
+	}
+	if err := db.Model(&testUser{ID: 1}).Update("name", "new").Error; err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateWithETag(t *testing.T) {
+	query := regexp.QuoteMeta("UPDATE `test_profiles` SET `update_time`=?,`version`=?,`name`=? WHERE `test_profiles`.`version` = ? AND `test_profiles`.`delete_time` IS NULL AND `id` = ?")
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectExec(query).WithArgs(sqlmock.AnyArg(), 3, "new", 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p := &testProfile{Common: model.Common{ID: 1}, Name: "new"}
+		if err := UpdateWithETag(db, p, ETag(2), "name"); err != nil {
+			t.Fatalf("UpdateWithETag() error = %v", err)
+		}
+		if p.ResourceVersion() != 3 {
+			t.Errorf("UpdateWithETag() version = %v, want 3", p.ResourceVersion())
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		p := &testProfile{Common: model.Common{ID: 1}, Name: "new"}
+		err := UpdateWithETag(db, p, `W/"2"`, "name")
+		conflict := &VersionConflictError{}
+		if !errors.As(err, &conflict) || conflict.Expected != 2 || !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("UpdateWithETag() error = %v, want %v", err, ErrVersionConflict)
+		}
+		if p.ResourceVersion() != 2 {
+			t.Errorf("UpdateWithETag() version = %v, want 2", p.ResourceVersion())
+		}
+	})
+
+	t.Run("invalid etag", func(t *testing.T) {
+		db, _ := newMockDB(t)
+		err := UpdateWithETag(db, &testProfile{}, "abc", "name")
+		if !errors.Is(err, ErrInvalidETag) {
+			t.Errorf("UpdateWithETag() error = %v, want %v", err, ErrInvalidETag)
+		}
+	})
+}
+
+func TestParseETag(t *testing.T) {
+	tests := []struct {
+		name    string
+		etag    string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "strong", etag: ETag(42), want: 42},
+		{name: "weak", etag: `W/"42"`, want: 42},
+		{name: "unquoted", etag: "42", want: 42},
+		{name: "invalid", etag: `"v42"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseETag(tt.etag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseETag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseETag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
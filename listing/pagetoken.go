@@ -6,7 +6,10 @@ import (
 	"fmt"
 )
 
-var ErrPageToken = errors.New("invalid page token")
+var (
+	ErrPageToken       = errors.New("invalid page token")
+	ErrInvalidPageSize = errors.New("invalid page size")
+)
 
 // CommonState represents the part of the pagination state that is common across all pagination implementations.
 type CommonState struct {
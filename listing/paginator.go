@@ -33,15 +33,15 @@ func splitRequest(req Request) (CommonState, []byte, error) {
 	if len(req.PageToken) != 0 {
 		pt, err := unmarshalPageToken(req.PageToken)
 		if err != nil {
-			return ps, nil, fmt.Errorf("invalid page token: %w", err)
+			return ps, nil, fmt.Errorf("%w: %v", ErrPageToken, err)
 		}
 
 		if pt.Common.Knobs != req.Knobs {
-			return ps, nil, fmt.Errorf("list parameters changed: %#v -> %#v", req.Knobs, pt.Common.Knobs)
+			return ps, nil, fmt.Errorf("%w: list parameters changed: %#v -> %#v", ErrPageToken, req.Knobs, pt.Common.Knobs)
 		}
 
 		if pt.Common.Collection != req.Collection {
-			return ps, nil, fmt.Errorf("invalid page token")
+			return ps, nil, ErrPageToken
 		}
 
 		return ps, pt.State, nil
@@ -55,7 +55,7 @@ func splitRequest(req Request) (CommonState, []byte, error) {
 // implState must be the
 func Init(req Request, commonState *CommonState, implState interface{}) error {
 	if req.Knobs.PageSize <= 0 {
-		return fmt.Errorf("invalid page size: %d", req.Knobs.PageSize)
+		return fmt.Errorf("%w: %d", ErrInvalidPageSize, req.Knobs.PageSize)
 	}
 
 	cs, bytes, err := splitRequest(req)
@@ -52,6 +52,20 @@ func GetMysqlDB() (*gorm.DB, error) {
 	return db.(*gorm.DB), nil
 }
 
+// GetMigratedMysqlDB works like GetMysqlDB and runs migrate on the database before returning it,
+// e.g. the Migrate method of a gormx migrate.Migrator.
+func GetMigratedMysqlDB(migrate func(db *gorm.DB) error) (*gorm.DB, error) {
+	db, err := GetMysqlDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		ReleaseMysqlDB(db)
+		return nil, err
+	}
+	return db, nil
+}
+
 func ReleaseMysqlDB(db *gorm.DB) {
 	_ = dropAllMysqlTables(db)
 	_ = dbPool.ReturnObject(context.Background(), db)